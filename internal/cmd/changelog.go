@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulumi/schema-tools/internal/util/diagtree"
+)
+
+func changelogCmd() *cobra.Command {
+	var provider, repository, oldCommit, newCommit string
+
+	command := &cobra.Command{
+		Use:   "changelog",
+		Short: "Print the changes between two versions of a Pulumi schema as changelog entries",
+		RunE: func(command *cobra.Command, args []string) error {
+			old := schemaSource{repository: repository, commit: oldCommit}
+			new := schemaSource{repository: repository, commit: newCommit}
+			schOld, schNew, err := loadSchemas(context.Background(), provider, old, new)
+			if err != nil {
+				return err
+			}
+			result, err := buildComparison(provider, schOld, schNew, compareOptions{})
+			if err != nil {
+				return err
+			}
+			renderChangelog(os.Stdout, provider, result)
+			return nil
+		},
+	}
+
+	command.Flags().StringVarP(&provider, "provider", "p", "", "the provider whose schema we are comparing")
+	_ = command.MarkFlagRequired("provider")
+
+	command.Flags().StringVarP(&repository, "repository", "r",
+		"github://api.github.com/pulumi", "the Git repository to download the schema file from")
+
+	command.Flags().StringVarP(&oldCommit, "old-commit", "o", "master",
+		"the old commit to compare with, e.g. the previous release")
+
+	command.Flags().StringVarP(&newCommit, "new-commit", "n", "",
+		"the new commit to describe the changes of")
+	_ = command.MarkFlagRequired("new-commit")
+
+	return command
+}
+
+// renderChangelog writes the comparison as Markdown bullets for a CHANGELOG.md, in sections for
+// what was added, removed and otherwise changed. Within a section, entries are in the order of
+// the text report.
+func renderChangelog(out io.Writer, provider string, result comparisonResult) {
+	var added, removed, changed []string
+	for _, name := range result.newResources {
+		added = append(added, fmt.Sprintf("Resource `%s`", name))
+	}
+	for _, name := range result.newFunctions {
+		added = append(added, fmt.Sprintf("Function `%s`", name))
+	}
+	result.violations.WalkDisplayed(func(path []string, n *diagtree.Node) {
+		entry := changelogEntry(provider, path, n)
+		if strings.HasPrefix(string(n.Code), "MISSING_") {
+			removed = append(removed, entry)
+		} else {
+			changed = append(changed, entry)
+		}
+	})
+
+	if len(added)+len(removed)+len(changed) == 0 {
+		fmt.Fprintln(out, "No changes to the schema.")
+		return
+	}
+	var sections int
+	for _, section := range []struct {
+		title   string
+		entries []string
+	}{
+		{"Added", added},
+		{"Removed", removed},
+		{"Changed", changed},
+	} {
+		if len(section.entries) == 0 {
+			continue
+		}
+		if sections > 0 {
+			fmt.Fprintln(out)
+		}
+		sections++
+		fmt.Fprintf(out, "### %s\n\n", section.title)
+		for _, entry := range section.entries {
+			fmt.Fprintf(out, "- %s\n", entry)
+		}
+	}
+}
+
+// changelogEntry describes the change n at path, e.g. "Resource `s3/bucket.Bucket`: inputs
+// `acl`: missing".
+func changelogEntry(provider string, path []string, n *diagtree.Node) string {
+	kinds := map[string]string{"Resources": "Resource", "Functions": "Function", "Types": "Type"}
+	// Who maintains a resource doesn't matter to its users.
+	if len(path) > 1 && path[0] == overlaySection {
+		path = path[1:]
+	}
+	var parts []string
+	for i, title := range path {
+		value, err := strconv.Unquote(title)
+		switch {
+		case i == 0 && kinds[title] != "":
+			parts = append(parts, kinds[title])
+		case i == 1 && kinds[path[0]] != "" && err == nil:
+			parts = append(parts, fmt.Sprintf("`%s`:", formatName(provider, value)))
+		case err == nil:
+			parts = append(parts, fmt.Sprintf("`%s`:", value))
+		default:
+			parts = append(parts, title)
+		}
+	}
+	return strings.Join(parts, " ") + " " + n.Description
+}
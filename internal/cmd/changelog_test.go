@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderChangelog(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["acl"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	oldSchema.Resources["my-pkg:index:Removed"] = simpleResource(nil, nil)
+
+	newSchema := simpleResourceSchema(simpleResource(nil, []string{"value"}))
+	newSchema.Resources["my-pkg:s3/bucket:Bucket"] = simpleResource(nil, nil)
+	newSchema.Functions = map[string]schema.FunctionSpec{"my-pkg:index:getBucket": {}}
+
+	result, err := buildComparison("my-pkg", oldSchema, newSchema, compareOptions{})
+	assert.NoError(t, err)
+	out := new(bytes.Buffer)
+	renderChangelog(out, "my-pkg", result)
+	assert.Equal(t, "### Added\n\n"+
+		"- Resource `s3/bucket.Bucket`\n"+
+		"- Function `index.getBucket`\n"+
+		"\n### Removed\n\n"+
+		"- Resource `index.MyResource`: inputs `acl`: missing\n"+
+		"- Resource `index.Removed`: missing\n"+
+		"\n### Changed\n\n"+
+		"- Resource `index.MyResource`: required inputs `value`: input has changed to Required\n",
+		out.String())
+
+	result, err = buildComparison("my-pkg", oldSchema, oldSchema, compareOptions{})
+	assert.NoError(t, err)
+	out.Reset()
+	renderChangelog(out, "my-pkg", result)
+	assert.Equal(t, "No changes to the schema.\n", out.String())
+}
@@ -3,16 +3,23 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"os/user"
+	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
-	"github.com/pulumi/pulumi/sdk/v3/go/common/util/contract"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/logging"
 	"github.com/spf13/cobra"
 
 	"github.com/pulumi/schema-tools/internal/pkg"
@@ -21,363 +28,1997 @@ import (
 )
 
 func compareCmd() *cobra.Command {
-	var provider, repository, oldCommit, newCommit string
-	var maxChanges int
+	var provider, repository, oldSource, newSource, oldCommit, newCommit, baselinePath, allowPath string
+	var oldPackage, newPackage, configPath, commentRepo, outPath string
+	var providers, failOnCategories []string
+	var maxChanges, pr int
+	var comment bool
+	var opts compareOptions
 
 	command := &cobra.Command{
 		Use:   "compare",
 		Short: "Compare two versions of a Pulumi schema",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return compare(provider, repository, oldCommit, newCommit, maxChanges)
+			if configPath != "" {
+				if err := applyConfigFile(cmd.Flags(), configPath); err != nil {
+					return err
+				}
+			}
+			if err := opts.validatePatterns(); err != nil {
+				return err
+			}
+			if err := opts.validateScopes(); err != nil {
+				return err
+			}
+			if err := validateFormat(opts.format, formats...); err != nil {
+				return err
+			}
+			opts.errOut = cmd.ErrOrStderr()
+			if opts.groupBy != groupByKind && opts.groupBy != groupByToken {
+				return fmt.Errorf("unknown grouping %q, expected one of %v",
+					opts.groupBy, []string{groupByKind, groupByToken})
+			}
+			if baselinePath != "" {
+				baseline, err := loadBaseline(baselinePath)
+				if err != nil {
+					return err
+				}
+				opts.baseline = baseline
+			}
+			if allowPath != "" {
+				allowed, err := loadAllowances(allowPath)
+				if err != nil {
+					return err
+				}
+				opts.allowed = allowed
+			}
+			if opts.stream {
+				switch {
+				case opts.format != formatJSONLines:
+					return fmt.Errorf(`"stream" requires --format %s`, formatJSONLines)
+				case opts.summary, allowPath != "", len(providers) > 0:
+					return errors.New(`"stream" cannot be used with "summary", "allow" or "providers"`)
+				}
+			}
+			if comment {
+				switch {
+				case pr <= 0 || commentRepo == "":
+					return errors.New(`"comment" requires "pr" and "repo"`)
+				case opts.format != formatText && opts.format != formatGitHub:
+					return fmt.Errorf(`"comment" requires --format %s or %s`, formatText, formatGitHub)
+				}
+			}
+			for _, c := range failOnCategories {
+				opts.failOn = append(opts.failOn, parseCode(c))
+			}
+			if oldSource == "" {
+				oldSource = repository
+			}
+			if newSource == "" {
+				newSource = repository
+			}
+			old := schemaSource{repository: oldSource, commit: oldCommit}
+			if oldPackage != "" {
+				d, err := pkg.ParsePackageDescriptor(oldPackage)
+				if err != nil {
+					return err
+				}
+				old.pkg = &d
+			}
+			new := schemaSource{repository: newSource, commit: newCommit}
+			if newPackage != "" {
+				d, err := pkg.ParsePackageDescriptor(newPackage)
+				if err != nil {
+					return err
+				}
+				new.pkg = &d
+			}
+			if new.pkg == nil && newCommit == "" {
+				return errors.New(`either "new-commit" or "new-package" must be set`)
+			}
+
+			manyProviders := len(providers) > 0
+			if manyProviders {
+				if provider != "" {
+					return errors.New(`"provider" and "providers" cannot be used together`)
+				}
+				// A package descriptor names the schema of a single provider.
+				if old.pkg != nil || new.pkg != nil {
+					return errors.New(`"old-package" and "new-package" cannot be used with "providers"`)
+				}
+				switch opts.format {
+				case formatText, formatPlain, formatGitHub:
+				default:
+					return fmt.Errorf(`"providers" only supports the %s, %s and %s formats`,
+						formatText, formatPlain, formatGitHub)
+				}
+			} else {
+				if provider == "" {
+					switch {
+					case new.pkg != nil:
+						provider = new.pkg.Name
+					case old.pkg != nil:
+						provider = old.pkg.Name
+					default:
+						return errors.New(`required flag(s) "provider" not set`)
+					}
+				}
+				providers = []string{provider}
+			}
+
+			out, closeOut, err := openOutput(outPath)
+			if err != nil {
+				return err
+			}
+			var report bytes.Buffer
+			if comment {
+				out = io.MultiWriter(out, &report)
+			}
+			if manyProviders {
+				err = compareProviders(out, providers, old, new, maxChanges, opts)
+			} else {
+				err = compare(out, provider, old, new, maxChanges, opts)
+			}
+			if closeErr := closeOut(); closeErr != nil {
+				return closeErr
+			}
+
+			// A report with breaking changes in failing categories is still worth posting.
+			if comment && report.Len() > 0 {
+				marker := commentMarker(providers)
+				body := marker + "\n" + report.String()
+				host := commentAPIHost(newSource)
+				if postErr := pkg.UpsertPRComment(context.Background(), host, commentRepo, pr, marker, body); postErr != nil {
+					postErr = fmt.Errorf("commenting on pull request %s#%d: %w", commentRepo, pr, postErr)
+					// Failing to post is a failure of the run, which takes precedence over thresholds,
+					// so only the message of err is kept.
+					if err != nil {
+						postErr = fmt.Errorf("%s\n%w", err, postErr)
+					}
+					err = postErr
+				}
+			}
+			return err
 		},
 	}
 
-	command.Flags().StringVarP(&provider, "provider", "p", "", "the provider whose schema we are comparing")
-	_ = command.MarkFlagRequired("provider")
+	command.Flags().StringVar(&configPath, "config", "",
+		"a YAML file setting any of these flags, e.g. schema-tools.yaml. Flags on the command line take precedence")
+
+	command.Flags().StringVarP(&provider, "provider", "p", "",
+		"the provider whose schema we are comparing (defaults to the name of --new-package or --old-package)")
+
+	command.Flags().StringSliceVar(&providers, "providers", nil,
+		"compare each of these comma separated providers in turn, instead of --provider")
+
+	command.Flags().StringVarP(&repository, "repository", "r",
+		"github://api.github.com/pulumi", "the Git repository to download the schema file from")
+
+	command.Flags().StringVar(&oldSource, "old-source", "",
+		"the Git repository to download the old schema from, e.g. github://api.github.com/pulumi/pulumi-aws "+
+			"(defaults to --repository)")
+
+	command.Flags().StringVar(&newSource, "new-source", "",
+		"the Git repository to download the new schema from, e.g. github://api.github.com/myfork/pulumi-aws "+
+			"(defaults to --repository)")
+
+	command.Flags().StringVarP(&oldCommit, "old-commit", "o", "master",
+		"the old commit to compare with (defaults to master)")
+
+	command.Flags().StringVarP(&newCommit, "new-commit", "n", "",
+		"the new commit to compare against the old commit")
+
+	command.Flags().StringVar(&oldPackage, "old-package", "",
+		"a published package to take the old schema from instead of a commit, e.g. aws@6.0.0")
+
+	command.Flags().StringVar(&newPackage, "new-package", "",
+		"a published package to take the new schema from instead of a commit, e.g. aws@6.1.0")
+
+	command.Flags().IntVarP(&maxChanges, "max-changes", "m", 500,
+		"the maximum number of breaking changes to display. Pass -1 to display all changes")
+
+	command.Flags().BoolVar(&opts.validate, "validate", false,
+		"check that both schemas are well formed before comparing them")
+
+	command.Flags().StringSliceVar(&failOnCategories, "fail-on-category", nil,
+		"fail when any breaking change has one of these comma separated codes, e.g. missing-resource,missing-function")
+
+	command.Flags().Float64Var(&opts.truncationThreshold, "truncation-threshold", 0.5,
+		"warn when the new schema has less than this share of the old schema's resources and functions")
+
+	command.Flags().BoolVar(&opts.failOnTruncation, "fail-on-truncation", false,
+		"fail instead of warning when the new schema appears truncated")
+
+	command.Flags().BoolVar(&opts.strictName, "strict-name", false,
+		"fail instead of warning when the schemas are for differently named packages")
+
+	command.Flags().BoolVar(&opts.deep, "deep", false,
+		"resolve changed #/types/ references and compare the shapes of the referenced types")
+
+	command.Flags().BoolVar(&opts.renamedRefs, "renamed-refs", false,
+		"report changed #/types/ references to identically shaped types as renames instead of type changes")
+
+	command.Flags().BoolVar(&opts.checkOrdering, "check-ordering", false,
+		"report required inputs of resources and functions that changed order")
+
+	command.Flags().BoolVar(&opts.checkDescriptions, "check-descriptions", false,
+		"report properties of resources, functions and types whose description was removed")
+
+	command.Flags().BoolVar(&opts.checkLanguage, "check-language", false,
+		"report changes to the language specific overrides of resources, functions, types and properties")
+
+	command.Flags().BoolVar(&opts.checkCycles, "check-cycles", false,
+		"report types that reference themselves in the new schema, directly or through other types, but didn't before")
+
+	command.Flags().StringVar(&opts.format, "format", formatText,
+		fmt.Sprintf("the output format, one of: %s", strings.Join(formats, ", ")))
+
+	command.Flags().StringVar(&opts.groupBy, "group-by", groupByKind,
+		fmt.Sprintf("how to group breaking changes in text output, one of: %s, %s", groupByKind, groupByToken))
+
+	command.Flags().BoolVar(&opts.summary, "summary", false,
+		"only print the number of breaking changes of each code, not the changes themselves")
+
+	command.Flags().BoolVar(&opts.includeNewInSummary, "include-new-in-summary", false,
+		"also count new resources and functions in the summary, as NEW_RESOURCE and NEW_FUNCTION")
+
+	command.Flags().BoolVar(&opts.stream, "stream", false,
+		"write breaking changes as each resource, function and type is compared, to use less memory on large "+
+			"schemas (requires --format json-lines)")
+
+	command.Flags().StringVar(&baselinePath, "baseline", "",
+		"a report from a previous run with --format json; breaking changes it lists are not reported again")
+
+	command.Flags().StringVar(&allowPath, "allow", "",
+		"a YAML or JSON list of approved breaking changes, which are reported as informational")
+
+	command.Flags().StringArrayVar(&opts.only, "only", nil,
+		"only compare resource, function and type tokens matching this glob (may be repeated)")
+
+	command.Flags().StringArrayVar(&opts.ignore, "ignore", nil,
+		"skip resource, function and type tokens matching this glob (may be repeated)")
+
+	command.Flags().StringSliceVar(&opts.scopes, "scope", nil,
+		fmt.Sprintf("only compare these sections of the schema, any of: %s (may be repeated)",
+			strings.Join(scopes, ", ")))
+
+	command.Flags().StringSliceVar(&opts.overlayPrefixes, "overlay-prefix", nil,
+		"report tokens with this prefix under a separate overlay section (may be repeated)")
+
+	command.Flags().BoolVar(&opts.overlayMarked, "overlay-marked", false,
+		"report tokens marked isOverlay in the old schema under a separate overlay section")
+
+	command.Flags().StringVar(&outPath, "out", "",
+		"write the report to this file instead of stdout, e.g. to compare it with a golden file")
+
+	command.Flags().BoolVar(&comment, "comment", false,
+		"also post the report as a comment on the pull request --pr of --repo, or update the one posted before")
+
+	command.Flags().IntVar(&pr, "pr", 0, "the number of the pull request to comment on")
+
+	command.Flags().StringVar(&commentRepo, "repo", "",
+		"the GitHub repository of the pull request to comment on, e.g. pulumi/pulumi-aws, on the GitHub host of --new-source")
+
+	return command
+}
+
+// Diagnostic codes attached to each breaking change. They are part of the machine readable
+// output, so existing codes must never change.
+const (
+	codeMissingResource     diagtree.Code = "MISSING_RESOURCE"
+	codeMissingFunction     diagtree.Code = "MISSING_FUNCTION"
+	codeMissingType         diagtree.Code = "MISSING_TYPE"
+	codeMissingConfig       diagtree.Code = "MISSING_CONFIG"
+	codeMissingInput        diagtree.Code = "MISSING_INPUT"
+	codeMissingOutput       diagtree.Code = "MISSING_OUTPUT"
+	codeMissingStateInput   diagtree.Code = "MISSING_STATE_INPUT"
+	codeMissingProperty     diagtree.Code = "MISSING_PROPERTY"
+	codeMissingEnumValue    diagtree.Code = "MISSING_ENUM_VALUE"
+	codeTypeChanged         diagtree.Code = "TYPE_CHANGED"
+	codeTypeWidened         diagtree.Code = "TYPE_WIDENED"
+	codeTypeNarrowed        diagtree.Code = "TYPE_NARROWED"
+	codeTypeRefRenamed      diagtree.Code = "TYPE_REF_RENAMED"
+	codeScalarToObject      diagtree.Code = "SCALAR_TO_OBJECT"
+	codeObjectToScalar      diagtree.Code = "OBJECT_TO_SCALAR"
+	codeUnionCaseRemoved    diagtree.Code = "UNION_CASE_REMOVED"
+	codeUnionCaseAdded      diagtree.Code = "UNION_CASE_ADDED"
+	codeDiscriminator       diagtree.Code = "DISCRIMINATOR_CHANGED"
+	codeOptionalToRequired  diagtree.Code = "OPTIONAL_TO_REQUIRED"
+	codeRequiredToOptional  diagtree.Code = "REQUIRED_TO_OPTIONAL"
+	codeRequiredWithDefault diagtree.Code = "REQUIRED_WITH_DEFAULT"
+	codeSignatureChanged    diagtree.Code = "SIGNATURE_CHANGED"
+	codeReplaceOnChanges    diagtree.Code = "REPLACE_ON_CHANGES_CHANGED"
+	codeResourceKind        diagtree.Code = "RESOURCE_KIND_CHANGED"
+	codeDefaultChanged      diagtree.Code = "DEFAULT_CHANGED"
+	codeDefaultEnvChanged   diagtree.Code = "DEFAULT_ENV_CHANGED"
+	codeOrderingChanged     diagtree.Code = "ORDERING_CHANGED"
+	codeDescriptionRemoved  diagtree.Code = "DESCRIPTION_REMOVED"
+	codeLanguageOverride    diagtree.Code = "LANGUAGE_OVERRIDE_CHANGED"
+	codeCycleIntroduced     diagtree.Code = "CYCLE_INTRODUCED"
+	codeInputOutputMoved    diagtree.Code = "INPUT_OUTPUT_MOVED"
+	codeObjectShape         diagtree.Code = "OBJECT_SHAPE_CHANGED"
+	codePlainness           diagtree.Code = "PLAINNESS_CHANGED"
+)
+
+// breakingCodes are the codes of all breaking changes, to check the codes given by users.
+var breakingCodes = set.FromSlice([]diagtree.Code{
+	codeMissingResource,
+	codeMissingFunction,
+	codeMissingType,
+	codeMissingConfig,
+	codeMissingInput,
+	codeMissingOutput,
+	codeMissingStateInput,
+	codeMissingProperty,
+	codeMissingEnumValue,
+	codeTypeChanged,
+	codeTypeWidened,
+	codeTypeNarrowed,
+	codeTypeRefRenamed,
+	codeScalarToObject,
+	codeObjectToScalar,
+	codeUnionCaseRemoved,
+	codeUnionCaseAdded,
+	codeDiscriminator,
+	codeOptionalToRequired,
+	codeRequiredToOptional,
+	codeRequiredWithDefault,
+	codeSignatureChanged,
+	codeReplaceOnChanges,
+	codeResourceKind,
+	codeDefaultChanged,
+	codeDefaultEnvChanged,
+	codeOrderingChanged,
+	codeDescriptionRemoved,
+	codeLanguageOverride,
+	codeCycleIntroduced,
+	codeInputOutputMoved,
+	codeObjectShape,
+	codePlainness,
+})
+
+// compareOptions controls the optional analyses performed when comparing two schemas, and
+// how their result is reported.
+type compareOptions struct {
+	// format is the output format of the report, one of formats.
+	format string
+
+	// errOut receives the warnings of the formats that have no room for them, or os.Stderr if
+	// nil.
+	errOut io.Writer
+
+	// groupBy is how breaking changes are grouped in text output, either groupByKind or
+	// groupByToken.
+	groupBy string
+
+	// summary reports the number of breaking changes of each code instead of listing them.
+	summary bool
+	// includeNewInSummary also counts new resources and functions in the summary, as if they
+	// were changes with their own codes.
+	includeNewInSummary bool
+
+	// stream compares and reports one token at a time to bound memory use, see
+	// streamComparison.
+	stream bool
+
+	// baseline holds the breaking changes of a previous run. They are left out of the report, so
+	// only newly introduced changes are shown.
+	baseline []jsonDiagnostic
+
+	// allowed lists approved breaking changes, which are reported as informational.
+	allowed []allowance
+
+	// validate checks that both schemas look like Pulumi schemas before comparing them.
+	validate bool
+
+	// truncationThreshold is the smallest share of the old schema's resources and functions
+	// that the new schema may have before it is reported as truncated. Zero disables the check.
+	truncationThreshold float64
+	// failOnTruncation aborts the comparison of a truncated schema instead of warning about it.
+	failOnTruncation bool
+	// strictName aborts the comparison of schemas with different package names instead of
+	// warning about it.
+	strictName bool
+	// failOn lists codes that fail the comparison when any breaking change has one of them,
+	// whatever its severity.
+	failOn []diagtree.Code
+
+	// deep resolves local #/types/ references whose tokens changed and compares the shapes
+	// of the referenced types, instead of reporting every token change as a type change. It
+	// also reports required-ness changes within referenced types under each property that
+	// reaches them, besides the Types section.
+	deep bool
+	// renamedRefs reports changed #/types/ references whose referenced types have the same
+	// shape as renames, rather than as type changes. It has no effect with deep.
+	renamedRefs bool
+
+	// checkOrdering reports required inputs that changed order, which breaks SDKs whose
+	// constructors take required arguments positionally.
+	checkOrdering bool
+
+	// checkDescriptions reports properties that lost their description, which degrades the
+	// generated docs without breaking any program.
+	checkDescriptions bool
+
+	// checkLanguage reports changes to the per-language overrides of resources, functions,
+	// types and properties, such as the name of a property in an SDK.
+	checkLanguage bool
+
+	// checkCycles reports types that became part of a reference cycle.
+	checkCycles bool
+
+	// only and ignore are glob patterns, as understood by path.Match, restricting which
+	// resource, function and type tokens are compared. A token is compared if it matches
+	// any pattern in only (or only is empty) and no pattern in ignore.
+	only, ignore []string
+
+	// scopes restricts the comparison to some sections of the schema, see scopes. All sections
+	// are compared when it is empty.
+	scopes []string
+
+	// overlayPrefixes are the token prefixes of hand-maintained overlay resources, functions and
+	// types. Their breaking changes are reported under their own section, see overlaySection.
+	overlayPrefixes []string
+	// overlayMarked also treats the tokens marked isOverlay in the old schema as overlays.
+	overlayMarked bool
+}
+
+// The sections of a schema that --scope can restrict the comparison to. The provider and its
+// config are only compared without --scope.
+const (
+	scopeResources = "resources"
+	scopeFunctions = "functions"
+	scopeTypes     = "types"
+)
+
+var scopes = []string{scopeResources, scopeFunctions, scopeTypes}
+
+func (opts compareOptions) validateScopes() error {
+	for _, scope := range opts.scopes {
+		if !slices.Contains(scopes, scope) {
+			return fmt.Errorf("unknown scope %q, expected one of %v", scope, scopes)
+		}
+	}
+	return nil
+}
+
+// inScope reports whether scope is compared. Passing "" asks whether the comparison covers the
+// whole schema.
+func (opts compareOptions) inScope(scope string) bool {
+	return len(opts.scopes) == 0 || scope != "" && slices.Contains(opts.scopes, scope)
+}
+
+func (opts compareOptions) validatePatterns() error {
+	for _, pattern := range append(append([]string{}, opts.only...), opts.ignore...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid token pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// includesToken reports whether token passes the --only and --ignore filters.
+func (opts compareOptions) includesToken(token string) bool {
+	matchesAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			// Patterns are validated up front, so we can ignore the error here.
+			if ok, _ := path.Match(pattern, token); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(opts.only) > 0 && !matchesAny(opts.only) {
+		return false
+	}
+	return !matchesAny(opts.ignore)
+}
+
+// filterTokens returns a copy of sch with only the resources, functions and types that
+// pass the --only and --ignore filters.
+func (opts compareOptions) filterTokens(sch schema.PackageSpec) schema.PackageSpec {
+	if len(opts.only) == 0 && len(opts.ignore) == 0 {
+		return sch
+	}
+	sch.Resources = filterMap(sch.Resources, opts.includesToken)
+	sch.Functions = filterMap(sch.Functions, opts.includesToken)
+	sch.Types = filterMap(sch.Types, opts.includesToken)
+	return sch
+}
+
+func filterMap[T any](m map[string]T, include func(string) bool) map[string]T {
+	filtered := make(map[string]T, len(m))
+	for k, v := range m {
+		if include(k) {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+// schemaSource locates one of the schemas to compare: a commit of a repository, or a
+// published package.
+type schemaSource struct {
+	repository, commit string
+	pkg                *pkg.PackageDescriptor
+}
+
+func (s schemaSource) load(ctx context.Context, provider string) (schema.PackageSpec, error) {
+	if s.pkg != nil {
+		return pkg.DownloadPackageSchema(ctx, *s.pkg)
+	}
+	return loadSchema(ctx, s.repository, provider, s.commit)
+}
+
+func (s schemaSource) String() string {
+	if s.pkg != nil {
+		return s.pkg.String()
+	}
+	return s.commit
+}
+
+func compare(out io.Writer, provider string, old, new schemaSource, maxChanges int, opts compareOptions) error {
+	schOld, schNew, err := loadSchemas(context.Background(), provider, old, new)
+	if err != nil {
+		return err
+	}
+	if opts.validate {
+		if err := validateSchemas(old, new, schOld, schNew); err != nil {
+			return err
+		}
+	}
+
+	logging.V(1).Infof("comparing %d resources, %d functions and %d types",
+		len(schOld.Resources), len(schOld.Functions), len(schOld.Types))
+	if err := compareSchemas(out, provider, schOld, schNew, maxChanges, opts); err != nil {
+		return err
+	}
+	logging.V(1).Infof("done")
+	return nil
+}
+
+// openOutput returns where to write the report: stdout, or the file at path if set. The returned
+// function closes the file.
+func openOutput(path string) (io.Writer, func() error, error) {
+	if path == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating the output file: %w", err)
+	}
+	return f, f.Close, nil
+}
+
+// commentMarker is hidden in the pull request comment about the comparison of providers, so that
+// later runs find and update it rather than adding another. Each set of providers has its own
+// comment, e.g. for CI jobs comparing one provider each.
+func commentMarker(providers []string) string {
+	return fmt.Sprintf("<!-- schema-tools compare %s -->", strings.Join(providers, ","))
+}
+
+// commentAPIHost is the GitHub REST API host, with any path prefix, serving repository, e.g.
+// "ghe.mycorp.com/api/v3" for github://ghe.mycorp.com/api/v3/pulumi. Pull requests are commented
+// on through api.github.com unless repository is a github:// URL.
+func commentAPIHost(repository string) string {
+	u, err := url.Parse(repository)
+	if err != nil || u.Scheme != "github" || u.Host == "" {
+		return "api.github.com"
+	}
+	if strings.HasPrefix(u.Path, "/api/v3/") {
+		return u.Host + "/api/v3"
+	}
+	return u.Host
+}
+
+func validateSchemas(old, new schemaSource, schOld, schNew schema.PackageSpec) error {
+	if err := pkg.ValidatePackageSpec(schOld); err != nil {
+		return fmt.Errorf("invalid old schema (%s): %w", old, err)
+	}
+	if err := pkg.ValidatePackageSpec(schNew); err != nil {
+		return fmt.Errorf("invalid new schema (%s): %w", new, err)
+	}
+	return nil
+}
+
+// compareProviders compares the old and new schemas of each of providers in turn, in a section
+// per provider, and ends with a summary of all of them. It fails if any provider couldn't be
+// compared or has breaking changes with a code in opts.failOn, after comparing the others.
+func compareProviders(out io.Writer, providers []string, old, new schemaSource, maxChanges int,
+	opts compareOptions) error {
+	heading, bullet := "## ", "- "
+	if opts.format == formatPlain {
+		heading, bullet = "== ", "* "
+	}
+
+	summaries := make([]string, len(providers))
+	var failed, gated int
+	for i, provider := range providers {
+		fmt.Fprintf(out, "%s%s\n\n", heading, provider)
+		result, err := func() (comparisonResult, error) {
+			schOld, schNew, err := loadSchemas(context.Background(), provider, old, new)
+			if err != nil {
+				return comparisonResult{}, err
+			}
+			if opts.validate {
+				if err := validateSchemas(old, new, schOld, schNew); err != nil {
+					return comparisonResult{}, err
+				}
+			}
+			return buildComparison(provider, schOld, schNew, opts)
+		}()
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, "Failed: %v\n\n", err)
+			summaries[i] = fmt.Sprintf("%s: failed", provider)
+			continue
+		}
+		if err := renderComparison(out, result, maxChanges, opts); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		if err := checkFailOn(result.failing, opts.failOn); err != nil {
+			gated++
+			fmt.Fprintf(out, "Failed: %v\n\n", err)
+		}
+
+		counts := result.violations.SeverityCounts()
+		var total int
+		for _, n := range counts {
+			total += n
+		}
+		if total == 0 {
+			summaries[i] = fmt.Sprintf("%s: no breaking changes", provider)
+		} else {
+			summaries[i] = fmt.Sprintf("%s: %d breaking change%s (%s)",
+				provider, total, plural(total), severitySummary(counts))
+		}
+	}
+
+	fmt.Fprintf(out, "%sSummary\n\n", heading)
+	for _, summary := range summaries {
+		fmt.Fprintln(out, bullet+summary)
+	}
+
+	var gatedErr error
+	if gated > 0 {
+		gatedErr = fmt.Errorf("%d of %d providers have breaking changes in failing categories",
+			gated, len(providers))
+	}
+	// Providers that couldn't be compared are a failure of the run, which takes precedence.
+	if failed > 0 {
+		return errors.Join(fmt.Errorf("%d of %d providers could not be compared", failed, len(providers)), gatedErr)
+	}
+	if gatedErr != nil {
+		return thresholdError{gatedErr}
+	}
+	return nil
+}
+
+// loadSchemas loads the old and new schemas concurrently, so that comparing two downloaded
+// schemas takes as long as the slower download rather than both. If either fails, the other is
+// canceled and the errors of both are returned, leaving out the cancellation itself.
+func loadSchemas(ctx context.Context, provider string, old, new schemaSource) (
+	schema.PackageSpec, schema.PackageSpec, error,
+) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var schemas [2]schema.PackageSpec
+	var errs [2]error
+	var wg sync.WaitGroup
+	for i, source := range []schemaSource{old, new} {
+		name := [2]string{"old", "new"}[i]
+		wg.Add(1)
+		go func(i int, source schemaSource) {
+			defer wg.Done()
+			logging.V(1).Infof("downloading the %s schema (%s)", name, source)
+			sch, err := source.load(ctx, provider)
+			if err != nil {
+				cancel()
+				errs[i] = fmt.Errorf("loading the %s schema (%s): %w", name, source, err)
+				return
+			}
+			schemas[i] = sch
+		}(i, source)
+	}
+	wg.Wait()
+
+	canceled := func(err error) bool { return errors.Is(err, context.Canceled) }
+	switch {
+	case canceled(errs[0]) && errs[1] != nil && !canceled(errs[1]):
+		errs[0] = nil
+	case canceled(errs[1]) && errs[0] != nil && !canceled(errs[0]):
+		errs[1] = nil
+	}
+	if err := errors.Join(errs[:]...); err != nil {
+		return schema.PackageSpec{}, schema.PackageSpec{}, err
+	}
+	logging.V(1).Infof("downloaded both schemas")
+	return schemas[0], schemas[1], nil
+}
+
+// loadSchema loads the schema of provider at commit. Besides a git reference, commit may be
+// "--local" to load the schema from the provider's checkout in $HOME/go/src, or
+// "--local-path=<path>" to load it from an arbitrary file.
+func loadSchema(ctx context.Context, repository, provider, commit string) (schema.PackageSpec, error) {
+	switch {
+	case commit == "--local":
+		usr, _ := user.Current()
+		basePath := fmt.Sprintf("%s/go/src/github.com/pulumi/%s", usr.HomeDir, provider)
+		schemaFile := pkg.StandardSchemaPath(provider)
+		schemaPath := filepath.Join(basePath, schemaFile)
+		return pkg.LoadLocalPackageSpec(schemaPath)
+	case strings.HasPrefix(commit, "--local-path="):
+		parts := strings.Split(commit, "=")
+		schemaPath, err := filepath.Abs(parts[1])
+		if err != nil {
+			return schema.PackageSpec{}, fmt.Errorf("unable to construct absolute path to schema.json: %w", err)
+		}
+		return pkg.LoadLocalPackageSpec(schemaPath)
+	default:
+		return pkg.DownloadSchema(ctx, repository, provider, commit)
+	}
+}
+
+func breakingChanges(oldSchema, newSchema schema.PackageSpec, opts compareOptions) *diagtree.Node {
+	msg := &diagtree.Node{Title: ""}
+	tc := newTypeComparer(&oldSchema, &newSchema, opts)
+	// Sections are displayed in the order they are added, and overlays are found in map order, so
+	// the sections are added upfront. Prune drops those left empty.
+	for _, section := range []string{"Resources", "Functions", "Types", overlaySection} {
+		msg.Label(section)
+	}
+
+	if opts.inScope(scopeResources) {
+		for resName := range oldSchema.Resources {
+			tc.compareResource(tc.tokenNode(msg, "Resources", resName), resName)
+		}
+	}
+	if opts.inScope(scopeFunctions) {
+		for funcName := range oldSchema.Functions {
+			tc.compareFunction(tc.tokenNode(msg, "Functions", funcName), funcName)
+		}
+	}
+	if opts.inScope(scopeTypes) {
+		for typName := range oldSchema.Types {
+			tc.compareType(tc.tokenNode(msg, "Types", typName), typName)
+		}
+		tc.compareCycles(msg)
+	}
+	if opts.inScope("") {
+		tc.compareProvider(msg)
+	}
+
+	msg.Prune()
+	return msg
+}
+
+// overlaySection holds the breaking changes of overlays: resources, functions and types that are
+// maintained by hand rather than generated, and so have different owners. Their codes are
+// summarized with an OVERLAY_ prefix, see summaryCode.
+const overlaySection = "Overlay"
+
+// tokenNode returns the node of token in section of msg, which is under overlaySection when the
+// token is an overlay.
+func (tc *typeComparer) tokenNode(msg *diagtree.Node, section, token string) *diagtree.Node {
+	if tc.isOverlay(section, token) {
+		msg = msg.Label(overlaySection)
+	}
+	return msg.Label(section).Value(token)
+}
+
+func (tc *typeComparer) isOverlay(section, token string) bool {
+	for _, prefix := range tc.opts.overlayPrefixes {
+		if strings.HasPrefix(token, prefix) {
+			return true
+		}
+	}
+	if !tc.opts.overlayMarked {
+		return false
+	}
+	switch section {
+	case "Resources":
+		return tc.oldSchema.Resources[token].IsOverlay
+	case "Functions":
+		return tc.oldSchema.Functions[token].IsOverlay
+	case "Types":
+		return tc.oldSchema.Types[token].IsOverlay
+	}
+	return false
+}
+
+// compareResource reports the breaking changes of the resource resName of the old schema on msg.
+func (tc *typeComparer) compareResource(msg *diagtree.Node, resName string) {
+	res := tc.oldSchema.Resources[resName]
+	newRes, ok := tc.newSchema.Resources[resName]
+	if !ok {
+		msg.SetDiagnostic(codeMissingResource, diagtree.Danger, "missing")
+		return
+	}
+
+	// A component is made of child resources registered by its provider, while a custom
+	// resource is managed through CRUD calls, so existing stacks are provisioned differently.
+	if res.IsComponent != newRes.IsComponent {
+		msg.Label("kind").SetDiagnostic(codeResourceKind, diagtree.Danger, "changed from a %s to a %s resource",
+			resourceKind(res), resourceKind(newRes))
+	}
+	tc.validateLanguage(res.Language, newRes.Language, msg)
+
+	for propName, prop := range res.InputProperties {
+		msg := msg.Label("inputs").Value(propName)
+		newProp, ok := newRes.InputProperties[propName]
+		if !ok {
+			if movedBetween(propName, res.Properties, newRes.Properties) {
+				msg.SetDiagnostic(codeInputOutputMoved, diagtree.Info, "moved from inputs to outputs")
+				continue
+			}
+			msg.SetDiagnostic(codeMissingInput, diagtree.Warn, "missing")
+			continue
+		}
+
+		tc.validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg, inputDirection)
+		tc.validateDescription(prop, newProp, msg)
+		tc.validateLanguage(prop.Language, newProp.Language, msg)
+		validateDefaults(prop, newProp, msg)
+	}
+
+	for propName, newProp := range newRes.InputProperties {
+		// A new input that forces replacement isn't a change in behavior for existing programs.
+		prop, ok := res.InputProperties[propName]
+		if !ok || replacesOnChanges(prop) == replacesOnChanges(newProp) {
+			continue
+		}
+		msg := msg.Label("replace on changes").Value(propName)
+		if replacesOnChanges(newProp) {
+			msg.SetDiagnostic(codeReplaceOnChanges, diagtree.Info, "changing this input now replaces the resource")
+		} else {
+			msg.SetDiagnostic(codeReplaceOnChanges, diagtree.Info, "changing this input no longer replaces the resource")
+		}
+	}
+
+	for propName, prop := range res.Properties {
+		msg := msg.Label("properties").Value(propName)
+		newProp, ok := newRes.Properties[propName]
+		if !ok {
+			if movedBetween(propName, res.InputProperties, newRes.InputProperties) {
+				msg.SetDiagnostic(codeInputOutputMoved, diagtree.Info, "moved from outputs to inputs")
+				continue
+			}
+			msg.SetDiagnostic(codeMissingOutput, diagtree.Warn, "missing output %q", propName)
+			continue
+		}
+
+		tc.validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg, outputDirection)
+		tc.validateDescription(prop, newProp, msg)
+		tc.validateLanguage(prop.Language, newProp.Language, msg)
+	}
+
+	tc.compareStateInputs(res.StateInputs, newRes.StateInputs, msg.Label("state inputs"))
+
+	oldRequiredInputs := set.FromSlice(res.RequiredInputs)
+	for _, input := range newRes.RequiredInputs {
+		msg := msg.Label("required inputs").Value(input)
+		if !oldRequiredInputs.Has(input) {
+			newlyRequired(msg, newRes.InputProperties, input, "input")
+		}
+	}
+	if tc.opts.checkOrdering {
+		validateOrdering(res.RequiredInputs, newRes.RequiredInputs, msg.Label("required inputs").Label("order"))
+	}
+
+	newRequiredProperties := set.FromSlice(newRes.Required)
+	for _, prop := range res.Required {
+		msg := msg.Label("required").Value(prop)
+		// It is a breaking change to move an output property from
+		// required to optional.
+		//
+		// If the property was removed, that breaking change is
+		// already warned on, so we don't need to warn here.
+		_, stillExists := newRes.Properties[prop]
+		if !newRequiredProperties.Has(prop) && stillExists {
+			msg.SetDiagnostic(codeRequiredToOptional, diagtree.Info, changedToOptional("property"))
+		}
+	}
+}
+
+// compareStateInputs reports the breaking changes of the state inputs of a resource, which look up
+// existing resources, e.g. with get in the SDKs or on import.
+func (tc *typeComparer) compareStateInputs(old, new *schema.ObjectTypeSpec, msg *diagtree.Node) {
+	if old == nil {
+		return
+	}
+	for propName, prop := range old.Properties {
+		msg := msg.Value(propName)
+		var newProp schema.PropertySpec
+		var ok bool
+		if new != nil {
+			newProp, ok = new.Properties[propName]
+		}
+		if !ok {
+			msg.SetDiagnostic(codeMissingStateInput, diagtree.Warn, "missing")
+			continue
+		}
+
+		tc.validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg, inputDirection)
+		tc.validateDescription(prop, newProp, msg)
+		tc.validateLanguage(prop.Language, newProp.Language, msg)
+	}
+}
+
+// compareFunction reports the breaking changes of the function funcName of the old schema on msg.
+func (tc *typeComparer) compareFunction(msg *diagtree.Node, funcName string) {
+	f := tc.oldSchema.Functions[funcName]
+	newFunc, ok := tc.newSchema.Functions[funcName]
+	if !ok {
+		msg.SetDiagnostic(codeMissingFunction, diagtree.Danger, "missing")
+		return
+	}
+	tc.validateLanguage(f.Language, newFunc.Language, msg)
+
+	if f.Inputs != nil {
+		msg := msg.Label("inputs")
+		validateObjectShape(f.Inputs, newFunc.Inputs, msg)
+		for propName, prop := range f.Inputs.Properties {
+			msg := msg.Value(propName)
+			if newFunc.Inputs == nil {
+				msg.SetDiagnostic(codeMissingInput, diagtree.Warn, "missing input %q", propName)
+				continue
+			}
+
+			newProp, ok := newFunc.Inputs.Properties[propName]
+			if !ok {
+				msg.SetDiagnostic(codeMissingInput, diagtree.Warn, "missing input %q", propName)
+				continue
+			}
+
+			tc.validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg, inputDirection)
+			tc.validateDescription(prop, newProp, msg)
+			tc.validateLanguage(prop.Language, newProp.Language, msg)
+			validateDefaults(prop, newProp, msg)
+		}
+
+		if newFunc.Inputs != nil {
+			msg := msg.Label("required")
+			oldRequired := set.FromSlice(f.Inputs.Required)
+			for _, req := range newFunc.Inputs.Required {
+				if !oldRequired.Has(req) {
+					newlyRequired(msg.Value(req), newFunc.Inputs.Properties, req, "input")
+				}
+			}
+			if tc.opts.checkOrdering {
+				validateOrdering(f.Inputs.Required, newFunc.Inputs.Required, msg.Label("order"))
+			}
+		}
+	}
+
+	// The upstream issue is tracked at
+	// https://github.com/pulumi/pulumi/issues/13563.
+	isNonZeroArgs := func(ts *schema.ObjectTypeSpec) bool {
+		if ts == nil {
+			return false
+		}
+		return len(ts.Properties) > 0
+	}
+	type nonZeroArgs struct{ old, new bool }
+	switch (nonZeroArgs{old: isNonZeroArgs(f.Inputs), new: isNonZeroArgs(newFunc.Inputs)}) {
+	case nonZeroArgs{false, true}:
+		msg.SetDiagnostic(codeSignatureChanged, diagtree.Danger,
+			"signature change (pulumi.InvokeOptions)->T => (Args, pulumi.InvokeOptions)->T")
+	case nonZeroArgs{true, false}:
+		msg.SetDiagnostic(codeSignatureChanged, diagtree.Danger,
+			"signature change (Args, pulumi.InvokeOptions)->T => (pulumi.InvokeOptions)->T")
+	}
+
+	oldOutputs, newOutputs := functionOutputs(f), functionOutputs(newFunc)
+	oldReturn, newReturn := functionReturnType(f), functionReturnType(newFunc)
+	switch {
+	case oldOutputs != nil && newReturn != nil:
+		msg.Label("outputs").SetDiagnostic(codeSignatureChanged, diagtree.Danger,
+			"signature change: returns %q instead of an object", typeSpecName(newReturn))
+	case oldReturn != nil && newOutputs != nil:
+		msg.Label("return type").SetDiagnostic(codeSignatureChanged, diagtree.Danger,
+			"signature change: returns an object instead of %q", typeSpecName(oldReturn))
+	case oldReturn != nil:
+		tc.validateTypes(oldReturn, newReturn, msg.Label("return type"), outputDirection)
+	case oldOutputs != nil:
+		msg := msg.Label("outputs")
+		validateObjectShape(oldOutputs, newOutputs, msg)
+		for propName, prop := range oldOutputs.Properties {
+			msg := msg.Value(propName)
+			if newOutputs == nil {
+				msg.SetDiagnostic(codeMissingOutput, diagtree.Warn, "missing output")
+				continue
+			}
+
+			newProp, ok := newOutputs.Properties[propName]
+			if !ok {
+				msg.SetDiagnostic(codeMissingOutput, diagtree.Warn, "missing output")
+				continue
+			}
+
+			tc.validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg, outputDirection)
+			tc.validateDescription(prop, newProp, msg)
+			tc.validateLanguage(prop.Language, newProp.Language, msg)
+		}
+
+		var newRequired set.Set[string]
+		if newOutputs != nil {
+			newRequired = set.FromSlice(newOutputs.Required)
+		}
+		msg = msg.Label("required")
+		for _, req := range oldOutputs.Required {
+			_, stillExists := oldOutputs.Properties[req]
+			if !newRequired.Has(req) && stillExists {
+				msg.Value(req).SetDiagnostic(
+					codeRequiredToOptional, diagtree.Info, changedToOptional("property"))
+			}
+		}
+	}
+}
+
+// compareType reports the breaking changes of the type typName of the old schema on msg.
+func (tc *typeComparer) compareType(msg *diagtree.Node, typName string) {
+	typ := tc.oldSchema.Types[typName]
+	newTyp, ok := tc.newSchema.Types[typName]
+	if !ok {
+		msg.SetDiagnostic(codeMissingType, diagtree.Danger, "missing")
+		return
+	}
+	tc.validateLanguage(typ.Language, newTyp.Language, msg)
+
+	validateEnums(typ.Enum, newTyp.Enum, msg, inputOutputDirection)
+
+	for propName, prop := range typ.Properties {
+		msg := msg.Label("properties").Value(propName)
+		newProp, ok := newTyp.Properties[propName]
+		if !ok {
+			msg.SetDiagnostic(codeMissingProperty, diagtree.Warn, "missing")
+			continue
+		}
+
+		tc.validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg, inputOutputDirection)
+		tc.validateDescription(prop, newProp, msg)
+		tc.validateLanguage(prop.Language, newProp.Language, msg)
+	}
+
+	// Since we don't know if this type will be consumed by pulumi (as an
+	// input) or by the user (as an output), this inherits the strictness of
+	// both inputs and outputs.
+	newRequired := set.FromSlice(newTyp.Required)
+	for _, r := range typ.Required {
+		_, stillExists := typ.Properties[r]
+		if !newRequired.Has(r) && stillExists {
+			msg.Label("required").Value(r).SetDiagnostic(
+				codeRequiredToOptional, diagtree.Info, changedToOptional("property"))
+		}
+	}
+	required := set.FromSlice(typ.Required)
+	for _, r := range newTyp.Required {
+		if !required.Has(r) {
+			msg.Label("required").Value(r).SetDiagnostic(
+				codeOptionalToRequired, diagtree.Info, changedToRequired("property"))
+		}
+	}
+}
+
+// compareCycles reports the types that reference each other in the new schema, but didn't in
+// the old one, with checkCycles. Some SDK generators can't handle recursive types.
+func (tc *typeComparer) compareCycles(msg *diagtree.Node) {
+	if !tc.opts.checkCycles {
+		return
+	}
+
+	// A cycle isn't new if its types were already all part of the same cycle.
+	oldCycle := map[string]int{}
+	for i, cycle := range pkg.TypeCycles(*tc.oldSchema) {
+		for _, token := range cycle {
+			oldCycle[token] = i + 1
+		}
+	}
+	for _, cycle := range pkg.TypeCycles(*tc.newSchema) {
+		known := oldCycle[cycle[0]] != 0
+		for _, token := range cycle[1:] {
+			known = known && oldCycle[token] == oldCycle[cycle[0]]
+		}
+		if known {
+			continue
+		}
+
+		msg := msg.Label("Types").Value(cycle[0]).Label("cycle")
+		if len(cycle) == 1 {
+			msg.SetDiagnostic(codeCycleIntroduced, diagtree.Warn, "now references itself")
+			continue
+		}
+		others := make([]string, len(cycle)-1)
+		for i, token := range cycle[1:] {
+			others[i] = fmt.Sprintf("%q", token)
+		}
+		msg.SetDiagnostic(codeCycleIntroduced, diagtree.Warn, "now references itself through %s",
+			strings.Join(others, ", "))
+	}
+}
+
+// compareProvider reports the breaking changes of the provider configuration and of the provider
+// resource's inputs on msg, the root of the report.
+func (tc *typeComparer) compareProvider(msg *diagtree.Node) {
+	// Provider configuration is set by users, so it is held to the same rules as resource inputs.
+	for varName, v := range tc.oldSchema.Config.Variables {
+		msg := msg.Label("Config").Value(varName)
+		newVar, ok := tc.newSchema.Config.Variables[varName]
+		if !ok {
+			msg.SetDiagnostic(codeMissingConfig, diagtree.Warn, "missing")
+			continue
+		}
+
+		tc.validateTypes(&v.TypeSpec, &newVar.TypeSpec, msg, inputDirection)
+		validateDefaults(v, newVar, msg)
+	}
+	oldRequiredConfig := set.FromSlice(tc.oldSchema.Config.Required)
+	for _, r := range tc.newSchema.Config.Required {
+		if !oldRequiredConfig.Has(r) {
+			newlyRequired(msg.Label("Config").Label("required").Value(r), tc.newSchema.Config.Variables, r, "config")
+		}
+	}
+
+	for propName, prop := range tc.oldSchema.Provider.InputProperties {
+		msg := msg.Label("Provider").Label("inputs").Value(propName)
+		newProp, ok := tc.newSchema.Provider.InputProperties[propName]
+		if !ok {
+			msg.SetDiagnostic(codeMissingInput, diagtree.Warn, "missing")
+			continue
+		}
+
+		tc.validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg, inputDirection)
+		validateDefaults(prop, newProp, msg)
+	}
+	oldRequiredProviderInputs := set.FromSlice(tc.oldSchema.Provider.RequiredInputs)
+	for _, input := range tc.newSchema.Provider.RequiredInputs {
+		if !oldRequiredProviderInputs.Has(input) {
+			newlyRequired(msg.Label("Provider").Label("required inputs").Value(input),
+				tc.newSchema.Provider.InputProperties, input, "input")
+		}
+	}
+}
+
+// validateDescription reports a property whose description was removed, with checkDescriptions.
+func (tc *typeComparer) validateDescription(old, new schema.PropertySpec, msg *diagtree.Node) {
+	if tc.opts.checkDescriptions && old.Description != "" && new.Description == "" {
+		msg.Label("description").SetDiagnostic(codeDescriptionRemoved, diagtree.Info, "description removed")
+	}
+}
+
+// validateLanguage reports the per-language overrides that were added, removed or changed, with
+// checkLanguage. The overrides are opaque to us, so any change may rename something in an SDK.
+func (tc *typeComparer) validateLanguage(old, new map[string]schema.RawMessage, msg *diagtree.Node) {
+	if !tc.opts.checkLanguage {
+		return
+	}
+	msg = msg.Label("language")
+	for lang, override := range old {
+		newOverride, ok := new[lang]
+		switch {
+		case !ok:
+			msg.Value(lang).SetDiagnostic(codeLanguageOverride, diagtree.Info, "override removed")
+		case !jsonEqual(override, newOverride):
+			msg.Value(lang).SetDiagnostic(codeLanguageOverride, diagtree.Info, "override changed")
+		}
+	}
+	for lang := range new {
+		if _, ok := old[lang]; !ok {
+			msg.Value(lang).SetDiagnostic(codeLanguageOverride, diagtree.Info, "override added")
+		}
+	}
+}
+
+// jsonEqual compares two JSON documents by value, ignoring formatting and the order of keys.
+func jsonEqual(a, b []byte) bool {
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// validateDefaults reports changes to the default value of an input, which change the behavior
+// of programs that don't set it.
+func validateDefaults(old, new schema.PropertySpec, msg *diagtree.Node) {
+	validateDefaultEnvironment(old.DefaultInfo, new.DefaultInfo, msg)
+
+	msg = msg.Label("default")
+	switch {
+	case old.Default == nil && new.Default == nil:
+	case old.Default == nil:
+		msg.SetDiagnostic(codeDefaultChanged, diagtree.Info, "default set to %s", formatDefault(new.Default))
+	case new.Default == nil:
+		msg.SetDiagnostic(codeDefaultChanged, diagtree.Info, "default %s removed", formatDefault(old.Default))
+	case !defaultsEqual(old.Default, new.Default):
+		msg.SetDiagnostic(codeDefaultChanged, diagtree.Info, "default changed from %s to %s",
+			formatDefault(old.Default), formatDefault(new.Default))
+	}
+}
+
+// validateDefaultEnvironment reports changes to the environment variables an input takes its
+// default from. Setups that rely on a variable that is no longer read silently lose the default.
+func validateDefaultEnvironment(old, new *schema.DefaultSpec, msg *diagtree.Node) {
+	var oldEnv, newEnv []string
+	if old != nil {
+		oldEnv = old.Environment
+	}
+	if new != nil {
+		newEnv = new.Environment
+	}
+	oldVars, newVars := set.FromSlice(oldEnv), set.FromSlice(newEnv)
 
-	command.Flags().StringVarP(&repository, "repository", "r",
-		"github://api.github.com/pulumi", "the Git repository to download the schema file from")
-	_ = command.MarkFlagRequired("provider")
+	msg = msg.Label("default environment")
+	for _, v := range oldEnv {
+		if !newVars.Has(v) {
+			msg.Value(v).SetDiagnostic(codeDefaultEnvChanged, diagtree.Info, "no longer read")
+		}
+	}
+	for _, v := range newEnv {
+		if !oldVars.Has(v) {
+			msg.Value(v).SetDiagnostic(codeDefaultEnvChanged, diagtree.Info, "now read")
+		}
+	}
+}
 
-	command.Flags().StringVarP(&oldCommit, "old-commit", "o", "master",
-		"the old commit to compare with (defaults to master)")
+// defaultsEqual compares two default values. Numbers are compared by value, since the same
+// default may be decoded as an int or a float64 depending on how the schema was loaded.
+func defaultsEqual(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
 
-	command.Flags().StringVarP(&newCommit, "new-commit", "n", "",
-		"the new commit to compare against the old commit")
-	_ = command.MarkFlagRequired("new-commit")
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
 
-	command.Flags().IntVarP(&maxChanges, "max-changes", "m", 500,
-		"the maximum number of breaking changes to display. Pass -1 to display all changes")
+func formatDefault(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
 
-	return command
+// newlyRequired reports the property name of props, of the given kind, that has changed to
+// required. A property with a default value is reported under its own code, since users don't
+// need to set it even when it is required.
+func newlyRequired(msg *diagtree.Node, props map[string]schema.PropertySpec, name, kind string) {
+	if prop, ok := props[name]; ok && (prop.Default != nil || prop.DefaultInfo != nil) {
+		msg.SetDiagnostic(codeRequiredWithDefault, diagtree.Info, "%s has changed to Required, with a default", kind)
+		return
+	}
+	msg.SetDiagnostic(codeOptionalToRequired, diagtree.Info, changedToRequired(kind))
 }
 
-func compare(provider string, repository string, oldCommit string, newCommit string, maxChanges int) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	var schOld schema.PackageSpec
-	schOldDone := make(chan error)
-	go func() {
-		var err error
-		schOld, err = pkg.DownloadSchema(ctx, repository, provider, oldCommit)
-		if err != nil {
-			cancel()
-		}
-		schOldDone <- err
-	}()
+// movedBetween reports whether a property that disappeared from one side of a resource, inputs
+// or outputs, appeared on the other side: it was not in old, but is in new.
+func movedBetween(name string, old, new map[string]schema.PropertySpec) bool {
+	_, wasThere := old[name]
+	_, isThere := new[name]
+	return !wasThere && isThere
+}
 
-	var schNew schema.PackageSpec
-	if newCommit == "--local" {
-		usr, _ := user.Current()
-		basePath := fmt.Sprintf("%s/go/src/github.com/pulumi/%s", usr.HomeDir, provider)
-		schemaFile := pkg.StandardSchemaPath(provider)
-		schemaPath := filepath.Join(basePath, schemaFile)
-		var err error
-		schNew, err = pkg.LoadLocalPackageSpec(schemaPath)
-		if err != nil {
-			return err
-		}
-	} else if strings.HasPrefix(newCommit, "--local-path=") {
-		parts := strings.Split(newCommit, "=")
-		schemaPath, err := filepath.Abs(parts[1])
-		if err != nil {
-			return fmt.Errorf("unable to construct absolute path to schema.json: %w", err)
-		}
-		schNew, err = pkg.LoadLocalPackageSpec(schemaPath)
-		if err != nil {
-			return err
-		}
-	} else {
-		var err error
-		schNew, err = pkg.DownloadSchema(ctx, repository, provider, newCommit)
+func resourceKind(res schema.ResourceSpec) string {
+	if res.IsComponent {
+		return "component"
+	}
+	return "custom"
+}
+
+// replacesOnChanges reports whether changing prop replaces its resource instead of updating it.
+func replacesOnChanges(prop schema.PropertySpec) bool {
+	return prop.ReplaceOnChanges || prop.WillReplaceOnChanges
+}
+
+func changedToRequired(kind string) string {
+	return fmt.Sprintf("%s has changed to Required", kind)
+}
+
+func changedToOptional(kind string) string {
+	return fmt.Sprintf("%s is no longer Required", kind)
+}
+
+func compareSchemas(out io.Writer, provider string, oldSchema, newSchema schema.PackageSpec, maxChanges int,
+	opts compareOptions) error {
+	if opts.format == formatJSONPatch {
+		oldSchema, newSchema = opts.filterTokens(oldSchema), opts.filterTokens(newSchema)
+		warnings, err := comparisonWarnings(oldSchema, newSchema, opts)
 		if err != nil {
 			return err
 		}
+		// As with json-lines, there is no room for warnings in the patch.
+		opts.printWarnings(warnings)
+		return renderJSONPatch(out, oldSchema, newSchema)
 	}
-
-	if err := <-schOldDone; err != nil {
+	if opts.stream {
+		return streamComparison(out, oldSchema, newSchema, opts)
+	}
+	result, err := buildComparison(provider, oldSchema, newSchema, opts)
+	if err != nil {
 		return err
 	}
-
-	compareSchemas(os.Stdout, provider, schOld, schNew, maxChanges)
-	return nil
+	if err := renderComparison(out, result, maxChanges, opts); err != nil {
+		return err
+	}
+	return checkFailOn(result.failing, opts.failOn)
 }
 
-func breakingChanges(oldSchema, newSchema schema.PackageSpec) *diagtree.Node {
-	msg := &diagtree.Node{Title: ""}
+// parseCode accepts a diagnostic code as written in the reports, or in lower case with dashes,
+// e.g. missing-resource for MISSING_RESOURCE.
+func parseCode(s string) diagtree.Code {
+	return diagtree.Code(strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), "-", "_")))
+}
 
-	changedToRequired := func(kind string) string {
-		return fmt.Sprintf("%s has changed to Required", kind)
+// checkFailOn fails when summary counts breaking changes with any of the codes in failOn.
+func checkFailOn(summary []summaryItem, failOn []diagtree.Code) error {
+	if len(failOn) == 0 {
+		return nil
 	}
-	changedToOptional := func(kind string) string {
-		return fmt.Sprintf("%s is no longer Required", kind)
+	failing := set.FromSlice(failOn)
+	var found []string
+	for _, item := range summary {
+		if failing.Has(item.Code) {
+			found = append(found, fmt.Sprintf("%s (%d)", item.Code, item.Count))
+		}
+	}
+	if len(found) == 0 {
+		return nil
 	}
+	return thresholdError{fmt.Errorf("found breaking changes in failing categories: %s", strings.Join(found, ", "))}
+}
 
-	for resName, res := range oldSchema.Resources {
-		msg := msg.Label("Resources").Value(resName)
-		newRes, ok := newSchema.Resources[resName]
-		if !ok {
-			msg.SetDescription(diagtree.Danger, "missing")
-			continue
+// buildComparison compares two schemas of provider, ready for renderComparison.
+func buildComparison(provider string, oldSchema, newSchema schema.PackageSpec,
+	opts compareOptions) (comparisonResult, error) {
+	oldSchema, newSchema = opts.filterTokens(oldSchema), opts.filterTokens(newSchema)
+
+	// Checked first, so that --strict-name doesn't wait for a comparison it then throws away.
+	warnings, err := comparisonWarnings(oldSchema, newSchema, opts)
+	if err != nil {
+		return comparisonResult{}, err
+	}
+	result := comparisonResult{
+		provider:   provider,
+		violations: breakingChanges(oldSchema, newSchema, opts),
+		churn:      countChurn(oldSchema, newSchema),
+		warnings:   warnings,
+	}
+	// Allowances are applied first, so that an approved change which is also in the baseline
+	// isn't reported as stale.
+	var allowedChanges map[*diagtree.Node]bool
+	if opts.allowed != nil {
+		result.staleAllowances, allowedChanges = applyAllowances(result.violations, opts.allowed)
+	}
+	if opts.baseline != nil {
+		subtractBaseline(result.violations, opts.baseline)
+	}
+	for resName := range newSchema.Resources {
+		if _, ok := oldSchema.Resources[resName]; !ok && opts.inScope(scopeResources) {
+			result.newResources = append(result.newResources, formatName(provider, resName))
+		}
+	}
+	for resName := range newSchema.Functions {
+		if _, ok := oldSchema.Functions[resName]; !ok && opts.inScope(scopeFunctions) {
+			result.newFunctions = append(result.newFunctions, formatName(provider, resName))
 		}
+	}
+	sort.Strings(result.newResources)
+	sort.Strings(result.newFunctions)
+	if opts.includeNewInSummary {
+		result.summary = summarizeNew(result.violations, result)
+	} else {
+		result.summary = summarize(result.violations)
+	}
+	result.failing = summarizeExcept(result.violations, allowedChanges)
+	result.byModule = countByModule(result.violations)
+	return result, nil
+}
 
-		for propName, prop := range res.InputProperties {
-			msg := msg.Label("inputs").Value(propName)
-			newProp, ok := newRes.InputProperties[propName]
-			if !ok {
-				msg.SetDescription(diagtree.Warn, "missing")
-				continue
-			}
+func renderComparison(out io.Writer, result comparisonResult, maxChanges int, opts compareOptions) error {
+	switch opts.format {
+	case formatJSON, formatJSONTree:
+		return renderJSON(out, result, opts.summary, opts.format == formatJSONTree)
+	case formatJSONLines:
+		return renderJSONLines(out, result, opts)
+	case formatHTML:
+		return renderHTML(out, result, opts.summary)
+	default:
+		renderText(out, result, maxChanges, opts)
+		return nil
+	}
+}
+
+// printWarnings writes warnings to opts.errOut, for the formats that have no room for them.
+func (opts compareOptions) printWarnings(warnings []string) {
+	errOut := opts.errOut
+	if errOut == nil {
+		errOut = os.Stderr
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(errOut, "warning: %s\n", warning)
+	}
+}
 
-			validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg)
+// comparisonWarnings returns the warnings that the schemas are probably not the ones meant to be
+// compared, or fails with the first of them that opts makes an error.
+func comparisonWarnings(oldSchema, newSchema schema.PackageSpec, opts compareOptions) ([]string, error) {
+	var warnings []string
+	// Comparing two different providers reports nearly everything as missing.
+	if oldSchema.Name != newSchema.Name {
+		warning := fmt.Sprintf("the schemas are for different packages: %q and %q", oldSchema.Name, newSchema.Name)
+		if opts.strictName {
+			return nil, thresholdError{errors.New(warning)}
 		}
+		warnings = append(warnings, warning)
+	}
+	if warning := truncationWarning(oldSchema, newSchema, opts); warning != "" {
+		if opts.failOnTruncation {
+			return nil, thresholdError{errors.New(warning)}
+		}
+		warnings = append(warnings, warning)
+	}
+	return warnings, nil
+}
 
-		for propName, prop := range res.Properties {
-			msg := msg.Label("properties").Value(propName)
-			newProp, ok := newRes.Properties[propName]
-			if !ok {
-				msg.SetDescription(diagtree.Warn, "missing output %q", propName)
-				continue
-			}
+// truncationWarning warns when the new schema has less than opts.truncationThreshold times the
+// resources and functions of the old one, counting only the sections in scope. This usually means
+// that the wrong file was compared or that codegen failed, rather than that hundreds of resources
+// were removed on purpose.
+func truncationWarning(oldSchema, newSchema schema.PackageSpec, opts compareOptions) string {
+	var oldCount, newCount int
+	var sections []string
+	if opts.inScope(scopeResources) {
+		oldCount, newCount = oldCount+len(oldSchema.Resources), newCount+len(newSchema.Resources)
+		sections = append(sections, scopeResources)
+	}
+	if opts.inScope(scopeFunctions) {
+		oldCount, newCount = oldCount+len(oldSchema.Functions), newCount+len(newSchema.Functions)
+		sections = append(sections, scopeFunctions)
+	}
+	if oldCount == 0 || float64(newCount) >= opts.truncationThreshold*float64(oldCount) {
+		return ""
+	}
+	return fmt.Sprintf("the new schema appears truncated: it has %d %s, down from %d",
+		newCount, strings.Join(sections, " and "), oldCount)
+}
 
-			validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg)
-		}
+// churnStats counts every property change between two schemas, breaking or not.
+type churnStats struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Retyped int `json:"retyped"`
+}
 
-		oldRequiredInputs := set.FromSlice(res.RequiredInputs)
-		for _, input := range newRes.RequiredInputs {
-			msg := msg.Label("required inputs").Value(input)
-			if !oldRequiredInputs.Has(input) {
-				msg.SetDescription(diagtree.Info, changedToRequired("input"))
+// countChurn compares the properties of every resource, function and type. Properties of
+// resources, functions and types that were added or removed altogether count as added or
+// removed.
+func countChurn(oldSchema, newSchema schema.PackageSpec) churnStats {
+	var churn churnStats
+	oldProps, newProps := propertySets(oldSchema), propertySets(newSchema)
+	for key, props := range oldProps {
+		for name, prop := range props {
+			newProp, ok := newProps[key][name]
+			switch {
+			case !ok:
+				churn.Removed++
+			case typeSpecName(&prop.TypeSpec) != typeSpecName(&newProp.TypeSpec):
+				churn.Retyped++
 			}
 		}
-
-		newRequiredProperties := set.FromSlice(newRes.Required)
-		for _, prop := range res.Required {
-			msg := msg.Label("required").Value(prop)
-			// It is a breaking change to move an output property from
-			// required to optional.
-			//
-			// If the property was removed, that breaking change is
-			// already warned on, so we don't need to warn here.
-			_, stillExists := newRes.Properties[prop]
-			if !newRequiredProperties.Has(prop) && stillExists {
-				msg.SetDescription(diagtree.Info, changedToOptional("property"))
+	}
+	for key, props := range newProps {
+		for name := range props {
+			if _, ok := oldProps[key][name]; !ok {
+				churn.Added++
 			}
 		}
 	}
+	return churn
+}
 
-	for funcName, f := range oldSchema.Functions {
-		msg := msg.Label("Functions").Value(funcName)
-		newFunc, ok := newSchema.Functions[funcName]
-		if !ok {
-			msg.SetDescription(diagtree.Danger, "missing")
-			continue
+// propertySets returns every set of properties in sch, keyed by where it is declared.
+func propertySets(sch schema.PackageSpec) map[string]map[string]schema.PropertySpec {
+	sets := map[string]map[string]schema.PropertySpec{}
+	for token, res := range sch.Resources {
+		sets["resources."+token+".inputs"] = res.InputProperties
+		sets["resources."+token+".properties"] = res.Properties
+	}
+	for token, f := range sch.Functions {
+		if f.Inputs != nil {
+			sets["functions."+token+".inputs"] = f.Inputs.Properties
 		}
+		if outputs := functionOutputs(f); outputs != nil {
+			sets["functions."+token+".outputs"] = outputs.Properties
+		}
+	}
+	for token, typ := range sch.Types {
+		sets["types."+token+".properties"] = typ.Properties
+	}
+	return sets
+}
 
-		if f.Inputs != nil {
-			msg := msg.Label("inputs")
-			for propName, prop := range f.Inputs.Properties {
-				msg := msg.Value(propName)
-				if newFunc.Inputs == nil {
-					msg.SetDescription(diagtree.Warn, "missing input %q", propName)
-					continue
-				}
+// typeComparer validates type changes between an old and a new schema.
+type typeComparer struct {
+	oldSchema, newSchema *schema.PackageSpec
+	opts                 compareOptions
 
-				newProp, ok := newFunc.Inputs.Properties[propName]
-				if !ok {
-					msg.SetDescription(diagtree.Warn, "missing input %q", propName)
-					continue
-				}
+	// inProgress holds the pairs of old and new type tokens currently being deep compared,
+	// so that recursive types terminate.
+	inProgress map[[2]string]bool
+}
 
-				validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg)
-			}
+func newTypeComparer(oldSchema, newSchema *schema.PackageSpec, opts compareOptions) *typeComparer {
+	return &typeComparer{
+		oldSchema:  oldSchema,
+		newSchema:  newSchema,
+		opts:       opts,
+		inProgress: map[[2]string]bool{},
+	}
+}
 
-			if newFunc.Inputs != nil {
-				msg := msg.Label("required")
-				oldRequired := set.FromSlice(f.Inputs.Required)
-				for _, req := range newFunc.Inputs.Required {
-					if !oldRequired.Has(req) {
-						msg.Value(req).SetDescription(diagtree.Info,
-							changedToRequired("input"))
-					}
-				}
-			}
+// direction describes which way values of a property flow between the user and the provider.
+type direction int
+
+const (
+	// inputDirection values are set by the user and consumed by the provider.
+	inputDirection direction = iota
+	// outputDirection values are produced by the provider and read by the user.
+	outputDirection
+	// inputOutputDirection values may flow either way, as is the case for shared object types.
+	inputOutputDirection
+)
+
+// widenedTypes maps each primitive type to the primitive type that can represent all of its values.
+var widenedTypes = map[string]string{
+	"integer": "number",
+}
+
+// typeChangeSeverity grades a change from oldType to newType flowing in dir.
+//
+// Widening a type is safe for outputs and breaking for inputs, while narrowing is the
+// reverse. Any other change is a warning.
+func typeChangeSeverity(oldType, newType string, dir direction) (diagtree.Severity, string, diagtree.Code) {
+	var widened bool
+	switch {
+	case widenedTypes[oldType] == newType:
+		widened = true
+	case widenedTypes[newType] == oldType:
+		widened = false
+	default:
+		return diagtree.Warn, "changed", codeTypeChanged
+	}
+
+	verb, code := "narrowed", codeTypeNarrowed
+	if widened {
+		verb, code = "widened", codeTypeWidened
+	}
+	switch {
+	case dir == inputOutputDirection:
+		return diagtree.Warn, verb, code
+	case widened == (dir == outputDirection):
+		return diagtree.Info, verb, code
+	default:
+		return diagtree.Danger, verb, code
+	}
+}
+
+func (tc *typeComparer) validateTypes(
+	old *schema.TypeSpec, new *schema.TypeSpec, msg *diagtree.Node, dir direction,
+) {
+	switch {
+	case old == nil && new == nil:
+		return
+	case old != nil && new == nil:
+		msg.SetDiagnostic(codeTypeChanged, diagtree.Warn, "had type %q but now has no type", typeSpecName(old))
+		return
+	case old == nil && new != nil:
+		msg.SetDiagnostic(codeTypeChanged, diagtree.Warn, "had no type but now has %q", typeSpecName(new))
+		return
+	}
+
+	// Plain values aren't wrapped in Output<T> by the SDKs, so their signatures change.
+	switch {
+	case !old.Plain && new.Plain:
+		msg.Label("plain").SetDiagnostic(codePlainness, diagtree.Info, "changed from an Output to a plain value")
+	case old.Plain && !new.Plain:
+		msg.Label("plain").SetDiagnostic(codePlainness, diagtree.Info, "changed from a plain value to an Output")
+	}
+
+	if len(old.OneOf) > 0 || len(new.OneOf) > 0 {
+		tc.validateUnions(old, new, msg, dir)
+		return
+	}
+
+	oldType, newType := typeSpecName(old), typeSpecName(new)
+	switch {
+	case oldType == newType:
+		if tc.opts.deep {
+			tc.locateRequiredRef(old.Ref, msg)
 		}
+	case tc.opts.deep && tc.validateRefs(old.Ref, new.Ref, msg, dir):
+	case tc.opts.renamedRefs && tc.sameShape(old.Ref, new.Ref, dir):
+		msg.SetDiagnostic(codeTypeRefRenamed, diagtree.Info,
+			"type reference renamed from %q to %q", oldType, newType)
+	// Switching between a scalar and an object changes the shape of the generated SDKs, so
+	// it deserves a clearer message than other type changes.
+	case isScalar(old) && isObject(tc.newSchema, new):
+		msg.SetDiagnostic(codeScalarToObject, diagtree.Warn,
+			"type changed from scalar %q to object %q", oldType, newType)
+	case isObject(tc.oldSchema, old) && isScalar(new):
+		msg.SetDiagnostic(codeObjectToScalar, diagtree.Warn,
+			"type changed from object %q to scalar %q", oldType, newType)
+	default:
+		severity, verb, code := typeChangeSeverity(oldType, newType, dir)
+		msg.SetDiagnostic(code, severity, "type %s from %q to %q", verb, oldType, newType)
+	}
 
-		// The upstream issue is tracked at
-		// https://github.com/pulumi/pulumi/issues/13563.
-		isNonZeroArgs := func(ts *schema.ObjectTypeSpec) bool {
-			if ts == nil {
-				return false
-			}
-			return len(ts.Properties) > 0
+	tc.validateTypes(old.Items, new.Items, msg.Label("items"), dir)
+
+	// A map whose values lose their type accepts anything, while a free-form map gaining a
+	// value type rejects values it used to accept. We only report this when the map itself
+	// is unchanged: otherwise the type change above already covers it.
+	apMsg := msg.Label("additional properties")
+	switch {
+	case oldType != newType:
+		return
+	case old.AdditionalProperties != nil && new.AdditionalProperties == nil:
+		apMsg.SetDiagnostic(codeTypeChanged, diagtree.Warn, "map value type was %q, now untyped",
+			typeSpecName(old.AdditionalProperties))
+		return
+	case old.AdditionalProperties == nil && new.AdditionalProperties != nil:
+		apMsg.SetDiagnostic(codeTypeNarrowed, diagtree.Warn, "map value type was untyped, now %q",
+			typeSpecName(new.AdditionalProperties))
+		return
+	}
+	tc.validateTypes(old.AdditionalProperties, new.AdditionalProperties, apMsg, dir)
+}
+
+// validateUnions compares the cases of two types where at least one is a oneOf. A type that
+// isn't a union is treated as a union with a single case, so that turning a plain type into
+// a oneOf that still includes it is only reported as added cases.
+func (tc *typeComparer) validateUnions(old, new *schema.TypeSpec, msg *diagtree.Node, dir direction) {
+	cases := func(ts *schema.TypeSpec) map[string]schema.TypeSpec {
+		if len(ts.OneOf) == 0 {
+			return map[string]schema.TypeSpec{typeSpecName(ts): *ts}
 		}
-		type nonZeroArgs struct{ old, new bool }
-		switch (nonZeroArgs{old: isNonZeroArgs(f.Inputs), new: isNonZeroArgs(newFunc.Inputs)}) {
-		case nonZeroArgs{false, true}:
-			msg.SetDescription(diagtree.Danger,
-				"signature change (pulumi.InvokeOptions)->T => (Args, pulumi.InvokeOptions)->T")
-		case nonZeroArgs{true, false}:
-			msg.SetDescription(diagtree.Danger,
-				"signature change (Args, pulumi.InvokeOptions)->T => (pulumi.InvokeOptions)->T")
+		m := make(map[string]schema.TypeSpec, len(ts.OneOf))
+		for _, c := range ts.OneOf {
+			m[typeSpecName(&c)] = c
 		}
+		return m
+	}
+	oldCases, newCases := cases(old), cases(new)
 
-		if f.Outputs != nil {
-			msg := msg.Label("outputs")
-			for propName, prop := range f.Outputs.Properties {
-				msg := msg.Value(propName)
-				if newFunc.Outputs == nil {
-					msg.SetDescription(diagtree.Warn, "missing output")
-					continue
-				}
+	for name, oldCase := range oldCases {
+		msg := msg.Label("one of").Value(name)
+		newCase, ok := newCases[name]
+		if !ok {
+			msg.SetDiagnostic(codeUnionCaseRemoved, diagtree.Warn, "union case removed")
+			continue
+		}
+		tc.validateTypes(&oldCase, &newCase, msg, dir)
+	}
+	for name := range newCases {
+		if _, ok := oldCases[name]; !ok {
+			msg.Label("one of").Value(name).SetDiagnostic(codeUnionCaseAdded, diagtree.Info, "union case added")
+		}
+	}
 
-				newProp, ok := newFunc.Outputs.Properties[propName]
-				if !ok {
-					msg.SetDescription(diagtree.Warn, "missing output")
-					continue
-				}
+	validateDiscriminators(old.Discriminator, new.Discriminator, msg.Label("discriminator"))
+}
 
-				validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg)
-			}
+func validateDiscriminators(old, new *schema.DiscriminatorSpec, msg *diagtree.Node) {
+	switch {
+	case old == nil && new == nil:
+		return
+	case old == nil:
+		msg.SetDiagnostic(codeDiscriminator, diagtree.Info, "discriminator %q added", new.PropertyName)
+		return
+	case new == nil:
+		msg.SetDiagnostic(codeDiscriminator, diagtree.Warn, "discriminator %q removed", old.PropertyName)
+		return
+	case old.PropertyName != new.PropertyName:
+		msg.SetDiagnostic(codeDiscriminator, diagtree.Warn,
+			"discriminator changed from %q to %q", old.PropertyName, new.PropertyName)
+	}
 
-			var newRequired set.Set[string]
-			if newFunc.Outputs != nil {
-				newRequired = set.FromSlice(newFunc.Outputs.Required)
-			}
-			msg = msg.Label("required")
-			for _, req := range f.Outputs.Required {
-				_, stillExists := f.Outputs.Properties[req]
-				if !newRequired.Has(req) && stillExists {
-					msg.Value(req).SetDescription(
-						diagtree.Info, changedToOptional("property"))
-				}
-			}
+	for value, oldRef := range old.Mapping {
+		msg := msg.Label("mapping").Value(value)
+		newRef, ok := new.Mapping[value]
+		switch {
+		case !ok:
+			msg.SetDiagnostic(codeDiscriminator, diagtree.Warn, "no longer maps to %q", oldRef)
+		case newRef != oldRef:
+			msg.SetDiagnostic(codeDiscriminator, diagtree.Warn, "maps to %q instead of %q", newRef, oldRef)
 		}
 	}
+}
+
+// validateRefs compares the shapes of the types referenced by oldRef and newRef, recording
+// any differences under msg.
+//
+// validateRefs returns false when the references cannot be compared structurally, either
+// because they are not local #/types/ references or because they don't resolve.
+func (tc *typeComparer) validateRefs(oldRef, newRef string, msg *diagtree.Node, dir direction) bool {
+	oldToken, ok := pkg.LocalTypeToken(oldRef)
+	if !ok {
+		return false
+	}
+	newToken, ok := pkg.LocalTypeToken(newRef)
+	if !ok {
+		return false
+	}
+	oldTyp, ok := tc.oldSchema.Types[oldToken]
+	if !ok {
+		return false
+	}
+	newTyp, ok := tc.newSchema.Types[newToken]
+	if !ok || oldTyp.Type != newTyp.Type {
+		return false
+	}
+
+	key := [2]string{oldToken, newToken}
+	if tc.inProgress[key] {
+		// We are already comparing this pair further up the stack. Any differences
+		// will be reported there.
+		return true
+	}
+	tc.inProgress[key] = true
+	defer delete(tc.inProgress, key)
 
-	for typName, typ := range oldSchema.Types {
-		msg := msg.Label("Types").Value(typName)
-		newTyp, ok := newSchema.Types[typName]
+	validateEnums(oldTyp.Enum, newTyp.Enum, msg, dir)
+
+	for propName, prop := range oldTyp.Properties {
+		msg := msg.Label("properties").Value(propName)
+		newProp, ok := newTyp.Properties[propName]
 		if !ok {
-			msg.SetDescription(diagtree.Danger, "missing")
+			msg.SetDiagnostic(codeMissingProperty, diagtree.Warn, "missing")
 			continue
 		}
 
-		for propName, prop := range typ.Properties {
-			msg := msg.Label("properties").Value(propName)
-			newProp, ok := newTyp.Properties[propName]
-			if !ok {
-				msg.SetDescription(diagtree.Warn, "missing")
-				continue
-			}
+		tc.validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg, dir)
+	}
 
-			validateTypes(&prop.TypeSpec, &newProp.TypeSpec, msg)
-		}
+	validateRequired(oldTyp.ObjectTypeSpec, newTyp.ObjectTypeSpec, msg)
+
+	return true
+}
 
-		// Since we don't know if this type will be consumed by pulumi (as an
-		// input) or by the user (as an output), this inherits the strictness of
-		// both inputs and outputs.
-		newRequired := set.FromSlice(newTyp.Required)
-		for _, r := range typ.Required {
-			_, stillExists := typ.Properties[r]
-			if !newRequired.Has(r) && stillExists {
-				msg.Label("required").Value(r).SetDescription(
-					diagtree.Info, changedToOptional("property"))
+// validateOrdering reports when the names present in both old and new are not in the same
+// relative order. Added and removed names are reported elsewhere.
+func validateOrdering(old, new []string, msg *diagtree.Node) {
+	common := func(names, others []string) []string {
+		keep := set.FromSlice(others)
+		var result []string
+		for _, name := range names {
+			if keep.Has(name) {
+				result = append(result, name)
 			}
 		}
-		required := set.FromSlice(typ.Required)
-		for _, r := range newTyp.Required {
-			if !required.Has(r) {
-				msg.Label("required").Value(r).SetDescription(
-					diagtree.Info, changedToRequired("property"))
-			}
+		return result
+	}
+	oldOrder, newOrder := common(old, new), common(new, old)
+	if !reflect.DeepEqual(oldOrder, newOrder) {
+		msg.SetDiagnostic(codeOrderingChanged, diagtree.Info, "changed from [%s] to [%s]",
+			strings.Join(oldOrder, ", "), strings.Join(newOrder, ", "))
+	}
+}
+
+// validateRequired reports the properties of an object type that became required or optional.
+func validateRequired(old, new schema.ObjectTypeSpec, msg *diagtree.Node) {
+	newRequired := set.FromSlice(new.Required)
+	for _, r := range old.Required {
+		_, stillExists := new.Properties[r]
+		if !newRequired.Has(r) && stillExists {
+			msg.Label("required").Value(r).SetDiagnostic(
+				codeRequiredToOptional, diagtree.Info, changedToOptional("property"))
+		}
+	}
+	required := set.FromSlice(old.Required)
+	for _, r := range new.Required {
+		if !required.Has(r) {
+			msg.Label("required").Value(r).SetDiagnostic(
+				codeOptionalToRequired, diagtree.Info, changedToRequired("property"))
 		}
 	}
+}
 
-	msg.Prune()
-	return msg
+// locateRequired reports the required-ness changes within the object types that old and new
+// reference, directly or through arrays and maps, when they reference the same types. The Types
+// section already reports these changes, this places them under each property reaching them.
+func (tc *typeComparer) locateRequired(old, new *schema.TypeSpec, msg *diagtree.Node) {
+	if old == nil || new == nil || typeSpecName(old) != typeSpecName(new) {
+		return
+	}
+	tc.locateRequired(old.Items, new.Items, msg.Label("items"))
+	tc.locateRequired(old.AdditionalProperties, new.AdditionalProperties, msg.Label("additional properties"))
+	tc.locateRequiredRef(old.Ref, msg)
 }
 
-func compareSchemas(out io.Writer, provider string, oldSchema, newSchema schema.PackageSpec, maxChanges int) {
-	fmt.Fprintf(out, "### Does the PR have any schema changes?\n\n")
-	violations := breakingChanges(oldSchema, newSchema)
-	displayedViolations := new(bytes.Buffer)
-	lenViolations := violations.Display(displayedViolations, maxChanges)
-	switch lenViolations {
-	case 0:
-		fmt.Fprintln(out, "Looking good! No breaking changes found.")
-	case 1:
-		fmt.Fprintln(out, "Found 1 breaking change: ")
-	default:
-		fmt.Fprintf(out, "Found %d breaking changes:\n", lenViolations)
+// locateRequiredRef is locateRequired for a reference that is unchanged between the schemas.
+func (tc *typeComparer) locateRequiredRef(ref string, msg *diagtree.Node) {
+	token, ok := pkg.LocalTypeToken(ref)
+	if !ok {
+		return
+	}
+	oldTyp, ok := tc.oldSchema.Types[token]
+	if !ok {
+		return
+	}
+	newTyp, ok := tc.newSchema.Types[token]
+	if !ok {
+		return
 	}
 
-	_, err := out.Write(displayedViolations.Bytes())
-	contract.AssertNoErrorf(err, "writing to a bytes.Buffer failing indicates OOM")
+	key := [2]string{token, token}
+	if tc.inProgress[key] {
+		return
+	}
+	tc.inProgress[key] = true
+	defer delete(tc.inProgress, key)
 
-	var newResources, newFunctions []string
-	for resName := range newSchema.Resources {
-		if _, ok := oldSchema.Resources[resName]; !ok {
-			newResources = append(newResources, formatName(provider, resName))
+	validateRequired(oldTyp.ObjectTypeSpec, newTyp.ObjectTypeSpec, msg)
+	for propName, prop := range oldTyp.Properties {
+		if newProp, ok := newTyp.Properties[propName]; ok {
+			tc.locateRequired(&prop.TypeSpec, &newProp.TypeSpec, msg.Label("properties").Value(propName))
 		}
 	}
-	for resName := range newSchema.Functions {
-		if _, ok := oldSchema.Functions[resName]; !ok {
-			newFunctions = append(newFunctions, formatName(provider, resName))
-		}
+}
+
+// sameShape reports whether oldRef and newRef are local #/types/ references to types with
+// the same properties, required properties and enum values, recursively.
+func (tc *typeComparer) sameShape(oldRef, newRef string, dir direction) bool {
+	oldToken, _ := pkg.LocalTypeToken(oldRef)
+	newToken, _ := pkg.LocalTypeToken(newRef)
+	oldTyp, newTyp := tc.oldSchema.Types[oldToken], tc.newSchema.Types[newToken]
+	if len(oldTyp.Properties) != len(newTyp.Properties) || len(oldTyp.Required) != len(newTyp.Required) ||
+		len(oldTyp.Enum) != len(newTyp.Enum) {
+		return false
 	}
 
-	if len(newResources) > 0 {
-		fmt.Fprintln(out, "\n#### New resources:")
-		fmt.Fprintln(out, "")
-		sort.Strings(newResources)
-		for _, v := range newResources {
-			fmt.Fprintf(out, "- `%s`\n", v)
-		}
+	// Having checked the sizes, any addition shows up as a removal, which validateRefs reports.
+	differences := new(diagtree.Node)
+	if !tc.validateRefs(oldRef, newRef, differences, dir) {
+		return false
+	}
+	same := true
+	differences.WalkDisplayed(func(_ []string, n *diagtree.Node) {
+		// Nested references may be renamed too.
+		same = same && n.Code == codeTypeRefRenamed
+	})
+	return same
+}
+
+// validateEnums reports the values of oldEnum missing from newEnum. Removing a value rejects
+// inputs that used to be accepted, while outputs simply take fewer values.
+func validateEnums(oldEnum, newEnum []schema.EnumValueSpec, msg *diagtree.Node, dir direction) {
+	severity := diagtree.Warn
+	switch dir {
+	case inputDirection:
+		severity = diagtree.Danger
+	case outputDirection:
+		severity = diagtree.Info
 	}
 
-	if len(newFunctions) > 0 {
-		fmt.Fprintln(out, "\n#### New functions:")
-		fmt.Fprintln(out, "")
-		sort.Strings(newFunctions)
-		for _, v := range newFunctions {
-			fmt.Fprintf(out, "- `%s`\n", v)
+	values := set.FromSlice(enumValues(newEnum))
+	for _, v := range enumValues(oldEnum) {
+		if !values.Has(v) {
+			msg.Label("enum").Value(v).SetDiagnostic(codeMissingEnumValue, severity, "missing")
 		}
 	}
+}
 
-	if len(newResources) == 0 && len(newFunctions) == 0 {
-		fmt.Fprintln(out, "No new resources/functions.")
+// typeSpecName returns the name of the type described by ts: its reference if it has one,
+// otherwise its primitive type.
+func typeSpecName(ts *schema.TypeSpec) string {
+	if ts.Ref != "" {
+		return ts.Ref
 	}
+	// Some hand-written schemas describe arrays with items alone, leaving out the type.
+	if ts.Type == "" && ts.Items != nil {
+		return "array"
+	}
+	return ts.Type
 }
 
-func validateTypes(old *schema.TypeSpec, new *schema.TypeSpec, msg *diagtree.Node) {
-	switch {
-	case old == nil && new == nil:
-		return
-	case old != nil && new == nil:
-		msg.SetDescription(diagtree.Warn, "had %+v but now has no type", old)
-		return
-	case old == nil && new != nil:
-		msg.SetDescription(diagtree.Warn, "had no type but now has %+v", new)
-		return
+// isScalar reports whether ts is a primitive type such as a string or a number.
+func isScalar(ts *schema.TypeSpec) bool {
+	if ts.Ref != "" {
+		return false
+	}
+	switch ts.Type {
+	case "string", "integer", "number", "boolean":
+		return true
+	}
+	return false
+}
+
+// isObject reports whether ts refers to an object type defined in sch.
+func isObject(sch *schema.PackageSpec, ts *schema.TypeSpec) bool {
+	token, ok := pkg.LocalTypeToken(ts.Ref)
+	if !ok {
+		return false
+	}
+	typ, ok := sch.Types[token]
+	return ok && typ.Type == "object"
+}
+
+// functionOutputs returns the object that f returns, whether it is declared with the
+// deprecated Outputs field or as an object ReturnType.
+func functionOutputs(f schema.FunctionSpec) *schema.ObjectTypeSpec {
+	if f.ReturnType != nil && f.ReturnType.ObjectTypeSpec != nil {
+		return f.ReturnType.ObjectTypeSpec
 	}
+	return f.Outputs
+}
 
-	oldType := old.Type
-	if old.Ref != "" {
-		oldType = old.Ref
+// validateObjectShape reports a change to the type of a function's inputs or outputs object as a
+// whole, rather than to its properties. Both objects are expected to be of type "object", which
+// is implied when the type is omitted.
+func validateObjectShape(old, new *schema.ObjectTypeSpec, msg *diagtree.Node) {
+	if old == nil || new == nil {
+		return
+	}
+	objectType := func(t string) string {
+		if t == "" {
+			return "object"
+		}
+		return t
 	}
-	newType := new.Type
-	if new.Ref != "" {
-		newType = new.Ref
+	if oldType, newType := objectType(old.Type), objectType(new.Type); oldType != newType {
+		msg.Label("type").SetDiagnostic(codeObjectShape, diagtree.Warn,
+			"type changed from %q to %q", oldType, newType)
 	}
-	if oldType != newType {
-		msg.SetDescription(diagtree.Warn, "type changed from %q to %q", oldType, newType)
+}
+
+// functionReturnType returns the non-object type that f returns, if any.
+func functionReturnType(f schema.FunctionSpec) *schema.TypeSpec {
+	if f.ReturnType != nil && f.ReturnType.ObjectTypeSpec == nil {
+		return f.ReturnType.TypeSpec
 	}
+	return nil
+}
 
-	validateTypes(old.Items, new.Items, msg.Label("items"))
-	validateTypes(old.AdditionalProperties, new.AdditionalProperties, msg.Label("additional properties"))
+func enumValues(enum []schema.EnumValueSpec) []string {
+	values := make([]string, 0, len(enum))
+	for _, e := range enum {
+		values = append(values, fmt.Sprintf("%v", e.Value))
+	}
+	return values
 }
 
+// formatName shortens a token for display, e.g. "aws:ec2/instance:Instance" becomes
+// "ec2/instance.Instance" when comparing aws. Tokens from other packages keep their package,
+// e.g. "aws:ec2/instance.Instance" when comparing awsx.
 func formatName(provider, s string) string {
-	return strings.ReplaceAll(strings.TrimPrefix(s, fmt.Sprintf("%s:", provider)), ":", ".")
+	pkg, rest, ok := strings.Cut(s, ":")
+	if !ok {
+		return s
+	}
+	rest = strings.ReplaceAll(rest, ":", ".")
+	if pkg == provider {
+		return rest
+	}
+	return pkg + ":" + rest
 }
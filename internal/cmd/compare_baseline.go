@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/pulumi/schema-tools/internal/util/diagtree"
+)
+
+// loadBaseline reads the breaking changes reported by a previous run of compare --format json.
+func loadBaseline(path string) ([]jsonDiagnostic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading baseline: %w", err)
+	}
+	var report jsonReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parsing baseline %s: %w", path, err)
+	}
+	// Anything else, e.g. a json-lines report, would otherwise be an empty baseline that lets
+	// every breaking change through as new.
+	if report.BreakingChanges == nil {
+		return nil, fmt.Errorf(`baseline %s has no "breaking_changes", expected a report from --format json`, path)
+	}
+	return report.BreakingChanges, nil
+}
+
+// subtractBaseline removes the breaking changes already present in baseline from violations.
+//
+// Changes are identified by their path and code, so rewording a description doesn't make a known
+// change new again.
+func subtractBaseline(violations *diagtree.Node, baseline []jsonDiagnostic) {
+	violations.Filter(notInBaseline(baseline))
+}
+
+// notInBaseline returns a diagtree.Filter predicate that drops the breaking changes in baseline.
+func notInBaseline(baseline []jsonDiagnostic) func([]string, *diagtree.Node) bool {
+	known := make(map[string]bool, len(baseline))
+	for _, d := range baseline {
+		known[diagnosticKey(d.Path, d.Code)] = true
+	}
+	return func(path []string, n *diagtree.Node) bool {
+		return !known[diagnosticKey(unquotePath(path), n.Code)]
+	}
+}
+
+// diagnosticKey identifies a breaking change by its path and code. Overlays are identified as if
+// they weren't, so that baselines and allow lists keep matching whether or not they are grouped.
+func diagnosticKey(path []string, code diagtree.Code) string {
+	if len(path) > 0 && path[0] == overlaySection {
+		path = path[1:]
+	}
+	return strings.Join(append(path[:len(path):len(path)], string(code)), "\x00")
+}
+
+// allowance approves a breaking change, identified like in --baseline by its path and code.
+type allowance struct {
+	Path   []string      `yaml:"path" json:"path"`
+	Code   diagtree.Code `yaml:"code" json:"code"`
+	Reason string        `yaml:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// loadAllowances reads a YAML or JSON list of allowances.
+func loadAllowances(path string) ([]allowance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading allow list: %w", err)
+	}
+	var allowed []allowance
+	if err := yaml.Unmarshal(data, &allowed); err != nil {
+		return nil, fmt.Errorf("parsing allow list %s: %w", path, err)
+	}
+	for i, a := range allowed {
+		if len(a.Path) == 0 || a.Code == "" {
+			return nil, fmt.Errorf("allow list %s: entry %d needs both a path and a code", path, i)
+		}
+		// Codes are written like for --fail-on-category, e.g. missing-input.
+		allowed[i].Code = parseCode(string(a.Code))
+		if !breakingCodes.Has(allowed[i].Code) {
+			return nil, fmt.Errorf("allow list %s: entry %d has unknown code %q", path, i, a.Code)
+		}
+	}
+	return allowed, nil
+}
+
+// applyAllowances downgrades the breaking changes matching an allowance to informational, and
+// returns the allowances that didn't match any change, along with the changes that did.
+func applyAllowances(violations *diagtree.Node, allowed []allowance) ([]allowance, map[*diagtree.Node]bool) {
+	// The same change may be listed more than once, e.g. when allow lists are merged. Each entry
+	// for a change is used by it, and the first gives the reason.
+	index := make(map[string][]int, len(allowed))
+	for i, a := range allowed {
+		key := diagnosticKey(a.Path, a.Code)
+		index[key] = append(index[key], i)
+	}
+	used := make([]bool, len(allowed))
+	matched := map[*diagtree.Node]bool{}
+	violations.WalkDisplayed(func(path []string, n *diagtree.Node) {
+		entries := index[diagnosticKey(unquotePath(path), n.Code)]
+		if len(entries) == 0 {
+			return
+		}
+		for _, i := range entries {
+			used[i] = true
+		}
+		matched[n] = true
+		n.Severity = diagtree.Info
+		if reason := allowed[entries[0]].Reason; reason != "" {
+			n.Description += fmt.Sprintf(" (allowed: %s)", reason)
+		} else {
+			n.Description += " (allowed)"
+		}
+	})
+
+	var stale []allowance
+	for i, a := range allowed {
+		if !used[i] {
+			stale = append(stale, a)
+		}
+	}
+	return stale, matched
+}
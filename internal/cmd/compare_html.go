@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/pulumi/schema-tools/internal/util/diagtree"
+)
+
+// htmlReport is the data rendered by htmlTemplate.
+type htmlReport struct {
+	Warnings     []string
+	Total        int
+	Groups       []htmlGroup
+	NewResources []string
+	NewFunctions []string
+}
+
+// htmlGroup holds the breaking changes sharing a code. Diagnostics is empty when only a summary
+// is requested.
+type htmlGroup struct {
+	Code        diagtree.Code
+	Count       int
+	Diagnostics []htmlDiagnostic
+}
+
+type htmlDiagnostic struct {
+	Path        string
+	Severity    string
+	Description string
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Schema changes</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin: 0.5em 0 1em; }
+th, td { border: 1px solid #d0d7de; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+summary { cursor: pointer; font-weight: 600; margin: 0.4em 0; }
+code { font-size: 0.9em; }
+.severity { font-weight: 600; text-transform: uppercase; font-size: 0.8em; }
+.danger { color: #cf222e; }
+.warn { color: #9a6700; }
+.info { color: #1a7f37; }
+</style>
+</head>
+<body>
+<h1>Schema changes</h1>
+{{- range .Warnings}}
+<p class="danger"><strong>Warning:</strong> {{.}}</p>
+{{- end}}
+{{- if .Total}}
+<p>Found {{.Total}} breaking change{{if ne .Total 1}}s{{end}}.</p>
+{{- range .Groups}}
+<details open>
+<summary>{{.Code}} ({{.Count}})</summary>
+{{- if .Diagnostics}}
+<table>
+<tr><th>Severity</th><th>Location</th><th>Description</th></tr>
+{{- range .Diagnostics}}
+<tr><td class="severity {{.Severity}}">{{.Severity}}</td><td><code>{{.Path}}</code></td><td>{{.Description}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</details>
+{{- end}}
+{{- else}}
+<p>Looking good! No breaking changes found.</p>
+{{- end}}
+{{- if .NewResources}}
+<h2>New resources</h2>
+<ul>
+{{- range .NewResources}}
+<li><code>{{.}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .NewFunctions}}
+<h2>New functions</h2>
+<ul>
+{{- range .NewFunctions}}
+<li><code>{{.}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+</body>
+</html>
+`))
+
+// renderHTML writes the comparison as a self-contained HTML page, with the breaking changes
+// grouped by code. With summary, only the number of changes per code is listed.
+func renderHTML(out io.Writer, result comparisonResult, summary bool) error {
+	diagnostics := jsonDiagnostics(result.violations)
+	byCode := map[diagtree.Code][]htmlDiagnostic{}
+	for _, d := range diagnostics {
+		byCode[d.Code] = append(byCode[d.Code], htmlDiagnostic{
+			Path:        strings.Join(d.Path, " / "),
+			Severity:    d.Severity.Name(),
+			Description: d.Description,
+		})
+	}
+
+	report := htmlReport{
+		Warnings:     result.warnings,
+		Total:        len(diagnostics),
+		NewResources: result.newResources,
+		NewFunctions: result.newFunctions,
+	}
+	for _, item := range summarize(result.violations) {
+		group := htmlGroup{Code: item.Code, Count: item.Count}
+		if !summary {
+			group.Diagnostics = byCode[item.Code]
+		}
+		report.Groups = append(report.Groups, group)
+	}
+	return htmlTemplate.Execute(out, report)
+}
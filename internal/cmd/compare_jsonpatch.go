@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+)
+
+// jsonPatchOp is a single RFC 6902 operation.
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// renderJSONPatch writes the RFC 6902 JSON Patch that transforms the JSON of oldSchema into the
+// JSON of newSchema. Unlike the other formats, this is a structural diff: it reports every
+// change, whether it breaks users or not.
+func renderJSONPatch(out io.Writer, oldSchema, newSchema schema.PackageSpec) error {
+	patch, err := jsonPatch(oldSchema, newSchema)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(patch)
+}
+
+// jsonPatch diffs the JSON documents of old and new.
+//
+// Operations are ordered by path, with object keys sorted, so that the patch of two schemas is
+// always the same. Elements past the end of the shorter array are removed from the back, so
+// that the patch applies in order.
+func jsonPatch(old, new any) ([]jsonPatchOp, error) {
+	oldDoc, err := toJSONDocument(old)
+	if err != nil {
+		return nil, err
+	}
+	newDoc, err := toJSONDocument(new)
+	if err != nil {
+		return nil, err
+	}
+	patch := []jsonPatchOp{}
+	err = diffJSON("", oldDoc, newDoc, &patch)
+	return patch, err
+}
+
+func toJSONDocument(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	err = json.Unmarshal(b, &doc)
+	return doc, err
+}
+
+func diffJSON(path string, old, new any, patch *[]jsonPatchOp) error {
+	op := func(kind, path string, value any) error {
+		o := jsonPatchOp{Op: kind, Path: path}
+		if kind != "remove" {
+			b, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			o.Value = b
+		}
+		*patch = append(*patch, o)
+		return nil
+	}
+
+	switch old := old.(type) {
+	case map[string]any:
+		new, ok := new.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(old)+len(new))
+		for k := range old {
+			keys = append(keys, k)
+		}
+		for k := range new {
+			if _, ok := old[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := path + "/" + escapeJSONPointer(k)
+			o, inOld := old[k]
+			n, inNew := new[k]
+			var err error
+			switch {
+			case !inNew:
+				err = op("remove", p, nil)
+			case !inOld:
+				err = op("add", p, n)
+			default:
+				err = diffJSON(p, o, n, patch)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		new, ok := new.([]any)
+		if !ok {
+			break
+		}
+		common := min(len(old), len(new))
+		for i := 0; i < common; i++ {
+			if err := diffJSON(path+"/"+strconv.Itoa(i), old[i], new[i], patch); err != nil {
+				return err
+			}
+		}
+		for i := len(old) - 1; i >= common; i-- {
+			if err := op("remove", path+"/"+strconv.Itoa(i), nil); err != nil {
+				return err
+			}
+		}
+		for i := common; i < len(new); i++ {
+			if err := op("add", path+"/"+strconv.Itoa(i), new[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if reflect.DeepEqual(old, new) {
+		return nil
+	}
+	return op("replace", path, new)
+}
+
+// escapeJSONPointer escapes a reference token of a JSON Pointer, as defined by RFC 6901.
+func escapeJSONPointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
@@ -0,0 +1,509 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/contract"
+
+	"github.com/pulumi/schema-tools/internal/pkg"
+	"github.com/pulumi/schema-tools/internal/util/diagtree"
+)
+
+// The output formats supported by the compare command.
+const (
+	formatText      = "text"
+	formatPlain     = "plain"
+	formatGitHub    = "github"
+	formatHTML      = "html"
+	formatJSON      = "json"
+	formatJSONLines = "json-lines"
+	formatJSONTree  = "json-tree"
+	formatJSONPatch = "jsonpatch"
+)
+
+var formats = []string{
+	formatText, formatPlain, formatGitHub, formatHTML, formatJSON, formatJSONLines, formatJSONTree,
+	formatJSONPatch,
+}
+
+// validateFormat checks that format is one of the allowed formats of a command.
+func validateFormat(format string, allowed ...string) error {
+	if slices.Contains(allowed, format) {
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, expected one of %v", format, allowed)
+}
+
+// comparisonResult is the outcome of comparing two schemas, ready to be rendered.
+type comparisonResult struct {
+	// provider is the name of the compared provider, which tokens are shown relative to.
+	provider string
+
+	violations *diagtree.Node
+
+	// newResources and newFunctions hold the sorted, formatted names of the resources and
+	// functions only present in the new schema.
+	newResources, newFunctions []string
+
+	churn churnStats
+
+	// warnings are problems with the comparison itself, shown before its result.
+	warnings []string
+
+	// staleAllowances are the entries of the allow list that didn't match any breaking change.
+	staleAllowances []allowance
+
+	// summary counts the breaking changes of each code and, with --include-new-in-summary, the
+	// new resources and functions.
+	summary []summaryItem
+
+	// failing counts the breaking changes of each code that --fail-on-category checks: all but
+	// the allowed ones.
+	failing []summaryItem
+
+	// byModule counts the breaking changes of the resources, functions and types of each module.
+	byModule []moduleCount
+}
+
+// renderText writes the comparison in one of the text formats: Markdown, plain text without Markdown
+// markup and severity emoji, or Markdown with the breaking changes folded into a collapsible section for
+// GitHub comments. With summary, only the number of changes per code is listed.
+func renderText(out io.Writer, result comparisonResult, maxChanges int, compareOpts compareOptions) {
+	format, summary := compareOpts.format, compareOpts.summary
+	opts, name := diagtree.MarkdownDisplayOptions, "`%s`"
+	if format == formatPlain {
+		opts, name = diagtree.PlainDisplayOptions, "%s"
+	}
+
+	for _, warning := range result.warnings {
+		if format == formatPlain {
+			fmt.Fprintf(out, "WARNING: %s\n\n", warning)
+		} else {
+			fmt.Fprintf(out, "> [!WARNING]\n> %s\n\n", warning)
+		}
+	}
+
+	fmt.Fprintf(out, "%sDoes the PR have any schema changes?\n\n", opts.Headings[0])
+	displayedViolations := new(bytes.Buffer)
+	var lenViolations int
+	if compareOpts.groupBy == groupByToken {
+		lenViolations = displayByToken(displayedViolations, result.provider, result.violations, maxChanges, opts, name)
+	} else {
+		lenViolations = result.violations.DisplayWith(displayedViolations, maxChanges, opts)
+	}
+	counts := severitySummary(result.violations.SeverityCounts())
+	collapse := format == formatGitHub && lenViolations > 0
+	if collapse {
+		fmt.Fprintln(out, "<details>")
+		fmt.Fprintf(out, "<summary>Found %d breaking change%s (%s)</summary>\n",
+			lenViolations, plural(lenViolations), counts)
+	} else {
+		switch lenViolations {
+		case 0:
+			fmt.Fprintln(out, "Looking good! No breaking changes found.")
+		case 1:
+			fmt.Fprintf(out, "Found 1 breaking change (%s): \n", counts)
+		default:
+			fmt.Fprintf(out, "Found %d breaking changes (%s):\n", lenViolations, counts)
+		}
+	}
+
+	if summary {
+		if collapse {
+			// GitHub only renders Markdown inside <details> after a blank line.
+			fmt.Fprintln(out)
+		}
+		for _, item := range result.summary {
+			fmt.Fprintf(out, opts.Bullet+name+": %d\n", item.Code, item.Count)
+		}
+	} else {
+		_, err := out.Write(displayedViolations.Bytes())
+		contract.AssertNoErrorf(err, "writing to a bytes.Buffer failing indicates OOM")
+	}
+	if collapse {
+		fmt.Fprintln(out, "\n</details>")
+	}
+
+	// With a single module, the breaking changes already say where they are.
+	if len(result.byModule) > 1 {
+		fmt.Fprintf(out, "\n%sBreaking changes by module:\n", opts.Headings[1])
+		fmt.Fprintln(out, "")
+		for _, m := range result.byModule {
+			fmt.Fprintf(out, opts.Bullet+name+": %d\n", m.Module, m.Count)
+		}
+	}
+
+	if len(result.newResources) > 0 {
+		fmt.Fprintf(out, "\n%sNew resources:\n", opts.Headings[1])
+		fmt.Fprintln(out, "")
+		for _, v := range result.newResources {
+			fmt.Fprintf(out, opts.Bullet+name+"\n", v)
+		}
+	}
+
+	if len(result.newFunctions) > 0 {
+		fmt.Fprintf(out, "\n%sNew functions:\n", opts.Headings[1])
+		fmt.Fprintln(out, "")
+		for _, v := range result.newFunctions {
+			fmt.Fprintf(out, opts.Bullet+name+"\n", v)
+		}
+	}
+
+	if len(result.newResources) == 0 && len(result.newFunctions) == 0 {
+		fmt.Fprintln(out, "No new resources/functions.")
+	}
+
+	fmt.Fprintf(out, "\nProperty churn: %d added, %d removed, %d retyped.\n",
+		result.churn.Added, result.churn.Removed, result.churn.Retyped)
+
+	if len(result.staleAllowances) > 0 {
+		fmt.Fprintf(out, "\n%sStale allow list entries:\n", opts.Headings[1])
+		fmt.Fprintln(out, "")
+		for _, a := range result.staleAllowances {
+			fmt.Fprintf(out, opts.Bullet+name+" %s\n", strings.Join(a.Path, " / "), a.Code)
+		}
+	}
+}
+
+// The ways breaking changes can be grouped in text output.
+const (
+	groupByKind  = "kind"
+	groupByToken = "token"
+)
+
+// displayByToken writes the breaking changes in sections for each resource, function and type
+// they belong to, largest first. Changes to the provider and its config are grouped under
+// "Provider" and "Config". Like diagtree.Node.Display, it returns the number of changes and
+// only writes the first max of them, unless max is -1.
+func displayByToken(out io.Writer, provider string, violations *diagtree.Node, max int,
+	opts diagtree.DisplayOptions, name string) int {
+	type entry struct {
+		path []string
+		n    *diagtree.Node
+	}
+	groups := map[string][]entry{}
+	violations.WalkDisplayed(func(path []string, n *diagtree.Node) {
+		path = unquotePath(path)
+		if path[0] == overlaySection && len(path) > 1 {
+			path = path[1:]
+		}
+		key, rest := path[0], path[1:]
+		switch key {
+		case "Resources", "Functions", "Types":
+			if len(path) > 1 {
+				key, rest = path[1], path[2:]
+			}
+		}
+		groups[key] = append(groups[key], entry{rest, n})
+	})
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(groups[keys[i]]) != len(groups[keys[j]]) {
+			return len(groups[keys[i]]) > len(groups[keys[j]])
+		}
+		return keys[i] < keys[j]
+	})
+
+	var count int
+	for _, key := range keys {
+		if max == -1 || count < max {
+			fmt.Fprintf(out, "\n%s"+name+" (%d)\n", opts.Headings[1], formatName(provider, key), len(groups[key]))
+		}
+		for _, e := range groups[key] {
+			if max == -1 || count < max {
+				var marker string
+				if opts.SeverityMarkers && e.n.Severity != diagtree.None {
+					marker = e.n.Severity.String() + " "
+				}
+				location := strings.Join(e.path, ": ")
+				if location != "" {
+					location += " "
+				}
+				fmt.Fprintf(out, "%s%s%s%s\n", opts.Bullet, marker, location, e.n.Description)
+			}
+			count++
+		}
+	}
+	// End with a blank line, like DisplayWith.
+	if count > 0 {
+		fmt.Fprintln(out)
+	}
+	return count
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// severitySummary formats counts as e.g. "3 danger, 7 warn, 2 info", most severe first and
+// skipping severities without diagnostics.
+func severitySummary(counts map[diagtree.Severity]int) string {
+	var parts []string
+	for _, s := range []diagtree.Severity{diagtree.Danger, diagtree.Warn, diagtree.Info} {
+		if counts[s] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[s], s.Name()))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// jsonDiagnostic is the machine readable form of a single breaking change.
+type jsonDiagnostic struct {
+	// Path holds the titles of the diagnostic's node and its ancestors, e.g.
+	// ["Resources", "aws:s3/bucket:Bucket", "inputs", "acl"].
+	Path        []string          `json:"path"`
+	Code        diagtree.Code     `json:"code"`
+	Severity    diagtree.Severity `json:"severity"`
+	Description string            `json:"description"`
+}
+
+// summaryItem counts the breaking changes with a given code.
+type summaryItem struct {
+	Code  diagtree.Code `json:"code"`
+	Count int           `json:"count"`
+}
+
+// Summary categories that count additions rather than breaking changes, see
+// --include-new-in-summary.
+const (
+	codeNewResource diagtree.Code = "NEW_RESOURCE"
+	codeNewFunction diagtree.Code = "NEW_FUNCTION"
+)
+
+// summarizeNew is like summarize, but also counts the new resources and functions of result,
+// ordered among the breaking changes by their number.
+func summarizeNew(violations *diagtree.Node, result comparisonResult) []summaryItem {
+	counts := countCodes(violations, nil)
+	if n := len(result.newResources); n > 0 {
+		counts[codeNewResource] = n
+	}
+	if n := len(result.newFunctions); n > 0 {
+		counts[codeNewFunction] = n
+	}
+	return summarizeCounts(counts)
+}
+
+// summarize counts the breaking changes of each code, most frequent first.
+func summarize(violations *diagtree.Node) []summaryItem {
+	return summarizeExcept(violations, nil)
+}
+
+// summarizeExcept is like summarize, but leaves out the breaking changes in skip.
+func summarizeExcept(violations *diagtree.Node, skip map[*diagtree.Node]bool) []summaryItem {
+	return summarizeCounts(countCodes(violations, skip))
+}
+
+// countCodes counts the breaking changes of each code, leaving out those in skip.
+func countCodes(violations *diagtree.Node, skip map[*diagtree.Node]bool) map[diagtree.Code]int {
+	counts := map[diagtree.Code]int{}
+	violations.WalkDisplayed(func(path []string, n *diagtree.Node) {
+		if !skip[n] {
+			counts[summaryCode(path, n.Code)]++
+		}
+	})
+	return counts
+}
+
+// moduleCount counts the breaking changes of a module.
+type moduleCount struct {
+	Module string `json:"module"`
+	Count  int    `json:"count"`
+}
+
+// countByModule counts the breaking changes of the resources, functions and types of each
+// module, most affected first. Changes to the provider and its config belong to no module.
+func countByModule(violations *diagtree.Node) []moduleCount {
+	counts := map[string]int{}
+	violations.WalkDisplayed(func(path []string, _ *diagtree.Node) {
+		path = unquotePath(path)
+		if path[0] == overlaySection {
+			path = path[1:]
+		}
+		if len(path) < 2 {
+			return
+		}
+		switch path[0] {
+		case "Resources", "Functions", "Types":
+			if module := pkg.ModuleName(path[1]); module != "" {
+				counts[module]++
+			}
+		}
+	})
+	modules := make([]moduleCount, 0, len(counts))
+	for module, count := range counts {
+		modules = append(modules, moduleCount{module, count})
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		if modules[i].Count != modules[j].Count {
+			return modules[i].Count > modules[j].Count
+		}
+		return modules[i].Module < modules[j].Module
+	})
+	return modules
+}
+
+// summaryCode is the code that a breaking change at path is counted under in summaries. Overlays
+// have codes of their own, so that --fail-on-category can tell them apart.
+func summaryCode(path []string, code diagtree.Code) diagtree.Code {
+	if len(path) > 0 && path[0] == overlaySection {
+		return "OVERLAY_" + code
+	}
+	return code
+}
+
+// summarizeCounts turns the number of breaking changes of each code into summary items, most
+// frequent first.
+func summarizeCounts(counts map[diagtree.Code]int) []summaryItem {
+	items := make([]summaryItem, 0, len(counts))
+	for code, count := range counts {
+		items = append(items, summaryItem{code, count})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Code < items[j].Code
+	})
+	return items
+}
+
+type jsonReport struct {
+	BreakingChanges []jsonDiagnostic `json:"breaking_changes"`
+	NewResources    []string         `json:"new_resources"`
+	NewFunctions    []string         `json:"new_functions"`
+	Churn           churnStats       `json:"churn"`
+	ByModule        []moduleCount    `json:"by_module,omitempty"`
+	StaleAllowances []allowance      `json:"stale_allowances,omitempty"`
+	Warnings        []string         `json:"warnings,omitempty"`
+}
+
+// jsonTreeReport is jsonReport with the breaking changes kept as a tree, as displayed in text.
+type jsonTreeReport struct {
+	BreakingChanges diagtree.JSONNode `json:"breaking_changes"`
+	NewResources    []string          `json:"new_resources"`
+	NewFunctions    []string          `json:"new_functions"`
+	Churn           churnStats        `json:"churn"`
+	ByModule        []moduleCount     `json:"by_module,omitempty"`
+	StaleAllowances []allowance       `json:"stale_allowances,omitempty"`
+	Warnings        []string          `json:"warnings,omitempty"`
+}
+
+func jsonDiagnostics(violations *diagtree.Node) []jsonDiagnostic {
+	diagnostics := []jsonDiagnostic{}
+	violations.WalkDisplayed(func(path []string, n *diagtree.Node) {
+		diagnostics = append(diagnostics, jsonDiagnostic{
+			Path:        unquotePath(path),
+			Code:        n.Code,
+			Severity:    n.Severity,
+			Description: n.Description,
+		})
+	})
+	return diagnostics
+}
+
+// jsonSummaryReport replaces jsonReport when only a summary of the breaking changes is requested.
+type jsonSummaryReport struct {
+	Summary      []summaryItem `json:"summary"`
+	NewResources []string      `json:"new_resources"`
+	NewFunctions []string      `json:"new_functions"`
+	Churn        churnStats    `json:"churn"`
+	// ByModule mirrors jsonReport.ByModule.
+	ByModule []moduleCount `json:"by_module,omitempty"`
+	// StaleAllowances mirrors jsonReport.StaleAllowances.
+	StaleAllowances []allowance `json:"stale_allowances,omitempty"`
+	Warnings        []string    `json:"warnings,omitempty"`
+}
+
+// unquotePath returns the titles of path without the quotes that Value nodes add for display.
+func unquotePath(path []string) []string {
+	titles := make([]string, len(path))
+	for i, title := range path {
+		if unquoted, err := strconv.Unquote(title); err == nil {
+			title = unquoted
+		}
+		titles[i] = title
+	}
+	return titles
+}
+
+// renderJSON writes the comparison as a single JSON document, listing the breaking changes or,
+// with tree, nesting them as in the text formats.
+func renderJSON(out io.Writer, result comparisonResult, summary, tree bool) error {
+	var report any = jsonReport{
+		BreakingChanges: jsonDiagnostics(result.violations),
+		NewResources:    nonNil(result.newResources),
+		NewFunctions:    nonNil(result.newFunctions),
+		Churn:           result.churn,
+		ByModule:        result.byModule,
+		StaleAllowances: result.staleAllowances,
+		Warnings:        result.warnings,
+	}
+	if tree {
+		report = jsonTreeReport{
+			BreakingChanges: result.violations.ToJSON(),
+			NewResources:    nonNil(result.newResources),
+			NewFunctions:    nonNil(result.newFunctions),
+			Churn:           result.churn,
+			ByModule:        result.byModule,
+			StaleAllowances: result.staleAllowances,
+			Warnings:        result.warnings,
+		}
+	}
+	if summary {
+		report = jsonSummaryReport{
+			Summary:         result.summary,
+			NewResources:    nonNil(result.newResources),
+			NewFunctions:    nonNil(result.newFunctions),
+			Churn:           result.churn,
+			ByModule:        result.byModule,
+			StaleAllowances: result.staleAllowances,
+			Warnings:        result.warnings,
+		}
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// renderJSONLines writes one JSON object per breaking change, or per code with summary.
+func renderJSONLines(out io.Writer, result comparisonResult, opts compareOptions) error {
+	// There is no room for warnings in the stream of breaking changes.
+	opts.printWarnings(result.warnings)
+	encoder := json.NewEncoder(out)
+	if opts.summary {
+		for _, item := range result.summary {
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, d := range jsonDiagnostics(result.violations) {
+		if err := encoder.Encode(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nonNil returns s, or an empty slice if s is nil, so that it serializes as [] rather than null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
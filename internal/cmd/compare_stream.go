@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen"
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+
+	"github.com/pulumi/schema-tools/internal/util/diagtree"
+)
+
+// streamComparison compares the schemas one resource, function or type at a time, and writes
+// the breaking changes of each as JSON lines before moving on to the next. Only the breaking
+// changes of the current token are held in memory, rather than the tree of the whole report.
+//
+// In exchange, nothing that needs the whole report is available: allowances, summaries and the
+// lists of new resources and functions.
+func streamComparison(out io.Writer, oldSchema, newSchema schema.PackageSpec, opts compareOptions) error {
+	oldSchema, newSchema = opts.filterTokens(oldSchema), opts.filterTokens(newSchema)
+	warnings, err := comparisonWarnings(oldSchema, newSchema, opts)
+	if err != nil {
+		return err
+	}
+	opts.printWarnings(warnings)
+
+	var keep func([]string, *diagtree.Node) bool
+	if opts.baseline != nil {
+		keep = notInBaseline(opts.baseline)
+	}
+	tc := newTypeComparer(&oldSchema, &newSchema, opts)
+	encoder := json.NewEncoder(out)
+	counts := map[diagtree.Code]int{}
+	emit := func(compare func(msg *diagtree.Node)) error {
+		msg := &diagtree.Node{Title: ""}
+		compare(msg)
+		msg.Prune()
+		if keep != nil {
+			msg.Filter(keep)
+		}
+		for _, d := range jsonDiagnostics(msg) {
+			counts[summaryCode(d.Path, d.Code)]++
+			if err := encoder.Encode(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if opts.inScope(scopeResources) {
+		for _, resName := range codegen.SortedKeys(oldSchema.Resources) {
+			err := emit(func(msg *diagtree.Node) {
+				tc.compareResource(tc.tokenNode(msg, "Resources", resName), resName)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if opts.inScope(scopeFunctions) {
+		for _, funcName := range codegen.SortedKeys(oldSchema.Functions) {
+			err := emit(func(msg *diagtree.Node) {
+				tc.compareFunction(tc.tokenNode(msg, "Functions", funcName), funcName)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if opts.inScope(scopeTypes) {
+		for _, typName := range codegen.SortedKeys(oldSchema.Types) {
+			err := emit(func(msg *diagtree.Node) {
+				tc.compareType(tc.tokenNode(msg, "Types", typName), typName)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := emit(tc.compareCycles); err != nil {
+			return err
+		}
+	}
+	if opts.inScope("") {
+		if err := emit(tc.compareProvider); err != nil {
+			return err
+		}
+	}
+
+	return checkFailOn(summarizeCounts(counts), opts.failOn)
+}
@@ -2,8 +2,15 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/h2non/gock"
 	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
 	"github.com/pulumi/schema-tools/internal/util/diagtree"
 	"github.com/stretchr/testify/assert"
@@ -70,10 +77,10 @@ func TestRemovedProperty(t *testing.T) {
 	old.Properties["field1"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
 	oldSchema := simpleResourceSchema(old)
 	newSchema := simpleResourceSchema(simpleResource(nil, nil))
-	changes := *breakingChanges(oldSchema, newSchema)
+	changes := *breakingChanges(oldSchema, newSchema, compareOptions{})
 	assert.Equal(t, expectedRes(func(n *diagtree.Node) {
 		n.Label("properties").Value("field1").
-			SetDescription(diagtree.Warn, `missing output "field1"`)
+			SetDiagnostic(codeMissingOutput, diagtree.Warn, `missing output "field1"`)
 	}), changes)
 
 }
@@ -203,7 +210,7 @@ func testBreakingRequired(
 			oldSchema := newT(tt.OldRequired, tt.OldRequiredInputs)
 			newSchema := newT(tt.NewRequired, tt.NewRequiredInputs)
 
-			violations := breakingChanges(oldSchema, newSchema)
+			violations := breakingChanges(oldSchema, newSchema, compareOptions{})
 
 			expected, actual := new(bytes.Buffer), new(bytes.Buffer)
 
@@ -244,3 +251,1701 @@ func simpleTypeSchema(t schema.ComplexTypeSpec) schema.PackageSpec {
 	}
 	return p
 }
+
+func TestDeepCompareRenamedType(t *testing.T) {
+	refSchema := func(ref string, types map[string]schema.ComplexTypeSpec) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["config"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Ref: "#/types/" + ref}}
+		p := simpleResourceSchema(r)
+		p.Types = types
+		return p
+	}
+	object := func(props map[string]schema.PropertySpec, required ...string) schema.ComplexTypeSpec {
+		return schema.ComplexTypeSpec{ObjectTypeSpec: schema.ObjectTypeSpec{
+			Type:       "object",
+			Properties: props,
+			Required:   required,
+		}}
+	}
+	recursive := func(token string) schema.ComplexTypeSpec {
+		return object(map[string]schema.PropertySpec{
+			"next": {TypeSpec: schema.TypeSpec{Ref: "#/types/" + token}},
+		})
+	}
+
+	oldSchema := refSchema("my-pkg:index:Old", map[string]schema.ComplexTypeSpec{
+		"my-pkg:index:Old": recursive("my-pkg:index:Old"),
+	})
+	identical := refSchema("my-pkg:index:New", map[string]schema.ComplexTypeSpec{
+		"my-pkg:index:Old": recursive("my-pkg:index:Old"),
+		"my-pkg:index:New": recursive("my-pkg:index:New"),
+	})
+	changed := refSchema("my-pkg:index:New", map[string]schema.ComplexTypeSpec{
+		"my-pkg:index:Old": recursive("my-pkg:index:Old"),
+		"my-pkg:index:New": object(map[string]schema.PropertySpec{
+			"next": {TypeSpec: schema.TypeSpec{Ref: "#/types/my-pkg:index:New"}},
+			"port": {TypeSpec: schema.TypeSpec{Type: "integer"}},
+		}, "port"),
+	})
+
+	t.Run("shallow", func(t *testing.T) {
+		changes := *breakingChanges(oldSchema, identical, compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("config").SetDiagnostic(codeTypeChanged, diagtree.Warn,
+				`type changed from "#/types/my-pkg:index:Old" to "#/types/my-pkg:index:New"`)
+		}), changes)
+	})
+
+	t.Run("deep identical", func(t *testing.T) {
+		changes := *breakingChanges(oldSchema, identical, compareOptions{deep: true})
+		assert.Equal(t, diagtree.Node{}, changes)
+	})
+
+	t.Run("deep changed", func(t *testing.T) {
+		changes := *breakingChanges(oldSchema, changed, compareOptions{deep: true})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("config").Label("required").Value("port").
+				SetDiagnostic(codeOptionalToRequired, diagtree.Info, "property has changed to Required")
+		}), changes)
+	})
+
+	t.Run("renamed identical", func(t *testing.T) {
+		changes := *breakingChanges(oldSchema, identical, compareOptions{renamedRefs: true})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("config").SetDiagnostic(codeTypeRefRenamed, diagtree.Info,
+				`type reference renamed from "#/types/my-pkg:index:Old" to "#/types/my-pkg:index:New"`)
+		}), changes)
+	})
+
+	t.Run("renamed changed", func(t *testing.T) {
+		changes := *breakingChanges(oldSchema, changed, compareOptions{renamedRefs: true})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("config").SetDiagnostic(codeTypeChanged, diagtree.Warn,
+				`type changed from "#/types/my-pkg:index:Old" to "#/types/my-pkg:index:New"`)
+		}), changes)
+	})
+}
+
+func TestDeepNestedRequired(t *testing.T) {
+	withRequired := func(required ...string) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["config"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Ref: "#/types/my-pkg:index:Config"}}
+		p := simpleResourceSchema(r)
+		p.Types = map[string]schema.ComplexTypeSpec{
+			"my-pkg:index:Config": {ObjectTypeSpec: schema.ObjectTypeSpec{
+				Type: "object",
+				Properties: map[string]schema.PropertySpec{
+					"settings": {TypeSpec: schema.TypeSpec{
+						Type:  "array",
+						Items: &schema.TypeSpec{Ref: "#/types/my-pkg:index:Settings"},
+					}},
+				},
+			}},
+			"my-pkg:index:Settings": {ObjectTypeSpec: schema.ObjectTypeSpec{
+				Type: "object",
+				Properties: map[string]schema.PropertySpec{
+					"port": {TypeSpec: schema.TypeSpec{Type: "integer"}},
+				},
+				Required: required,
+			}},
+		}
+		return p
+	}
+
+	display := func(opts compareOptions) string {
+		out := new(bytes.Buffer)
+		breakingChanges(withRequired(), withRequired("port"), opts).DisplayWith(out, -1, diagtree.PlainDisplayOptions)
+		return out.String()
+	}
+
+	assert.Equal(t, "\n"+
+		"Types\n"+
+		"* \"my-pkg:index:Settings\": required: \"port\" property has changed to Required\n", display(compareOptions{}))
+
+	assert.Equal(t, "\n"+
+		"Resources\n"+
+		"* \"my-pkg:index:MyResource\": inputs: \"config\": properties: \"settings\": items: required: \"port\" "+
+		"property has changed to Required\n"+
+		"Types\n"+
+		"* \"my-pkg:index:Config\": properties: \"settings\": items: required: \"port\" "+
+		"property has changed to Required\n"+
+		"* \"my-pkg:index:Settings\": required: \"port\" property has changed to Required\n",
+		display(compareOptions{deep: true}))
+}
+
+func TestEnumValues(t *testing.T) {
+	enum := func(values ...string) schema.ComplexTypeSpec {
+		typ := schema.ComplexTypeSpec{ObjectTypeSpec: schema.ObjectTypeSpec{Type: "string"}}
+		for _, v := range values {
+			typ.Enum = append(typ.Enum, schema.EnumValueSpec{Value: v})
+		}
+		return typ
+	}
+
+	t.Run("type", func(t *testing.T) {
+		changes := *breakingChanges(simpleTypeSchema(enum("a", "b")), simpleTypeSchema(enum("a")), compareOptions{})
+		assert.Equal(t, expectedTyp(func(n *diagtree.Node) {
+			n.Label("enum").Value("b").SetDiagnostic(codeMissingEnumValue, diagtree.Warn, "missing")
+		}), changes)
+	})
+
+	// The new schema keeps the old type, so that only the enum of the referenced type differs.
+	refSchema := func(output bool, ref string, values ...string) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		prop := schema.PropertySpec{TypeSpec: schema.TypeSpec{Ref: "#/types/" + ref}}
+		if output {
+			r.Properties["value"] = prop
+		} else {
+			r.InputProperties["value"] = prop
+		}
+		p := simpleResourceSchema(r)
+		p.Types = map[string]schema.ComplexTypeSpec{
+			"my-pkg:index:Old": enum("a", "b"),
+			ref:                enum(values...),
+		}
+		return p
+	}
+
+	for _, tt := range []struct {
+		output   bool
+		label    string
+		severity diagtree.Severity
+	}{
+		{false, "inputs", diagtree.Danger},
+		{true, "properties", diagtree.Info},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			changes := *breakingChanges(
+				refSchema(tt.output, "my-pkg:index:Old", "a", "b"),
+				refSchema(tt.output, "my-pkg:index:New", "a"),
+				compareOptions{deep: true})
+			assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+				n.Label(tt.label).Value("value").Label("enum").Value("b").
+					SetDiagnostic(codeMissingEnumValue, tt.severity, "missing")
+			}), changes)
+		})
+	}
+}
+
+func TestTypeWideningAndNarrowing(t *testing.T) {
+	withType := func(typ string) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["value"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: typ}}
+		r.Properties["value"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: typ}}
+		return simpleResourceSchema(r)
+	}
+
+	t.Run("widened", func(t *testing.T) {
+		changes := *breakingChanges(withType("integer"), withType("number"), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("value").SetDiagnostic(codeTypeWidened, diagtree.Danger,
+				`type widened from "integer" to "number"`)
+			n.Label("properties").Value("value").SetDiagnostic(codeTypeWidened, diagtree.Info,
+				`type widened from "integer" to "number"`)
+		}), changes)
+	})
+
+	t.Run("narrowed", func(t *testing.T) {
+		changes := *breakingChanges(withType("number"), withType("integer"), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("value").SetDiagnostic(codeTypeNarrowed, diagtree.Info,
+				`type narrowed from "number" to "integer"`)
+			n.Label("properties").Value("value").SetDiagnostic(codeTypeNarrowed, diagtree.Danger,
+				`type narrowed from "number" to "integer"`)
+		}), changes)
+	})
+}
+
+func TestFunctionReturnType(t *testing.T) {
+	outputs := &schema.ObjectTypeSpec{
+		Properties: map[string]schema.PropertySpec{
+			"value": {TypeSpec: schema.TypeSpec{Type: "string"}},
+		},
+	}
+	withOutputs := simpleFunctionSchema(schema.FunctionSpec{Outputs: outputs})
+	withObjectReturn := simpleFunctionSchema(schema.FunctionSpec{
+		ReturnType: &schema.ReturnTypeSpec{ObjectTypeSpec: outputs},
+	})
+	withScalarReturn := func(typ string) schema.PackageSpec {
+		return simpleFunctionSchema(schema.FunctionSpec{
+			ReturnType: &schema.ReturnTypeSpec{TypeSpec: &schema.TypeSpec{Type: typ}},
+		})
+	}
+
+	t.Run("outputs to object return type", func(t *testing.T) {
+		changes := *breakingChanges(withOutputs, withObjectReturn, compareOptions{})
+		assert.Equal(t, diagtree.Node{}, changes)
+	})
+
+	t.Run("scalar return type changed", func(t *testing.T) {
+		changes := *breakingChanges(withScalarReturn("string"), withScalarReturn("boolean"), compareOptions{})
+		assert.Equal(t, expectedFunc(func(n *diagtree.Node) {
+			n.Label("return type").SetDiagnostic(codeTypeChanged, diagtree.Warn,
+				`type changed from "string" to "boolean"`)
+		}), changes)
+	})
+
+	t.Run("outputs to scalar return type", func(t *testing.T) {
+		changes := *breakingChanges(withOutputs, withScalarReturn("string"), compareOptions{})
+		assert.Equal(t, expectedFunc(func(n *diagtree.Node) {
+			n.Label("outputs").SetDiagnostic(codeSignatureChanged, diagtree.Danger,
+				`signature change: returns "string" instead of an object`)
+		}), changes)
+	})
+
+	t.Run("scalar return type to outputs", func(t *testing.T) {
+		changes := *breakingChanges(withScalarReturn("string"), withObjectReturn, compareOptions{})
+		assert.Equal(t, expectedFunc(func(n *diagtree.Node) {
+			n.Label("return type").SetDiagnostic(codeSignatureChanged, diagtree.Danger,
+				`signature change: returns an object instead of "string"`)
+		}), changes)
+	})
+}
+
+func TestCheckOrdering(t *testing.T) {
+	oldSchema := simpleResourceSchema(simpleResource(nil, []string{"value", "list"}))
+	newSchema := simpleResourceSchema(simpleResource(nil, []string{"list", "value"}))
+
+	changes := breakingChanges(oldSchema, newSchema, compareOptions{})
+	assert.Equal(t, 0, changes.Display(new(bytes.Buffer), -1))
+
+	assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+		n.Label("required inputs").Label("order").SetDiagnostic(codeOrderingChanged, diagtree.Info,
+			"changed from [value, list] to [list, value]")
+	}), *breakingChanges(oldSchema, newSchema, compareOptions{checkOrdering: true}))
+
+	withRequired := func(required ...string) schema.PackageSpec {
+		return simpleFunctionSchema(schema.FunctionSpec{Inputs: &schema.ObjectTypeSpec{
+			Properties: map[string]schema.PropertySpec{
+				"a": {TypeSpec: schema.TypeSpec{Type: "string"}},
+				"b": {TypeSpec: schema.TypeSpec{Type: "string"}},
+				"c": {TypeSpec: schema.TypeSpec{Type: "string"}},
+			},
+			Required: required,
+		}})
+	}
+
+	t.Run("added inputs keep the order", func(t *testing.T) {
+		changes := breakingChanges(withRequired("a", "b"), withRequired("a", "c", "b"), compareOptions{checkOrdering: true})
+		assert.Equal(t, expectedFunc(func(n *diagtree.Node) {
+			n.Label("inputs").Label("required").Value("c").SetDiagnostic(codeOptionalToRequired, diagtree.Info,
+				"input has changed to Required")
+		}), *changes)
+	})
+
+	t.Run("function", func(t *testing.T) {
+		changes := breakingChanges(withRequired("a", "b"), withRequired("b", "a"), compareOptions{checkOrdering: true})
+		assert.Equal(t, expectedFunc(func(n *diagtree.Node) {
+			n.Label("inputs").Label("required").Label("order").SetDiagnostic(codeOrderingChanged, diagtree.Info,
+				"changed from [a, b] to [b, a]")
+		}), *changes)
+	})
+}
+
+func TestTokenFilters(t *testing.T) {
+	tests := []struct {
+		only, ignore []string
+		token        string
+		expected     bool
+	}{
+		{token: "aws:ec2/instance:Instance", expected: true},
+		{only: []string{"aws:ec2/*"}, token: "aws:ec2/instance:Instance", expected: true},
+		{only: []string{"aws:ec2/*"}, token: "aws:s3/bucket:Bucket", expected: false},
+		{only: []string{"aws:ec2/*", "aws:s3/*"}, token: "aws:s3/bucket:Bucket", expected: true},
+		{ignore: []string{"aws:deprecated/*"}, token: "aws:deprecated/old:Old", expected: false},
+		{
+			only:     []string{"aws:*"},
+			ignore:   []string{"aws:deprecated/*"},
+			token:    "aws:deprecated/old:Old",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		opts := compareOptions{only: tt.only, ignore: tt.ignore}
+		assert.NoError(t, opts.validatePatterns())
+		assert.Equal(t, tt.expected, opts.includesToken(tt.token), tt.token)
+	}
+
+	assert.Error(t, compareOptions{only: []string{"["}}.validatePatterns())
+}
+
+func TestScopes(t *testing.T) {
+	oldSchema := simpleResourceSchema(simpleResource(nil, nil))
+	oldSchema.Resources["my-pkg:index:Removed"] = simpleResource(nil, nil)
+	oldSchema.Functions = map[string]schema.FunctionSpec{"my-pkg:index:getRemoved": {}}
+	oldSchema.Types = map[string]schema.ComplexTypeSpec{"my-pkg:index:Removed": {}}
+	oldSchema.Config.Variables = map[string]schema.PropertySpec{"removed": {}}
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+	newSchema.Resources["my-pkg:index:Added"] = simpleResource(nil, nil)
+	newSchema.Functions = map[string]schema.FunctionSpec{"my-pkg:index:getAdded": {}}
+
+	summary := func(scopes ...string) []summaryItem {
+		opts := compareOptions{scopes: scopes, includeNewInSummary: true}
+		assert.NoError(t, opts.validateScopes())
+		result, err := buildComparison("my-pkg", oldSchema, newSchema, opts)
+		assert.NoError(t, err)
+		return result.summary
+	}
+
+	assert.Equal(t, []summaryItem{
+		{codeMissingConfig, 1},
+		{codeMissingFunction, 1},
+		{codeMissingResource, 1},
+		{codeMissingType, 1},
+		{codeNewFunction, 1},
+		{codeNewResource, 1},
+	}, summary())
+	assert.Equal(t, []summaryItem{{codeMissingResource, 1}, {codeNewResource, 1}}, summary(scopeResources))
+	assert.Equal(t, []summaryItem{
+		{codeMissingFunction, 1},
+		{codeMissingType, 1},
+		{codeNewFunction, 1},
+	}, summary(scopeFunctions, scopeTypes))
+
+	assert.EqualError(t, compareOptions{scopes: []string{"config"}}.validateScopes(),
+		`unknown scope "config", expected one of [resources functions types]`)
+}
+
+func TestOverlays(t *testing.T) {
+	overlay := simpleResource(nil, nil)
+	overlay.IsOverlay = true
+	oldSchema := simpleResourceSchema(simpleResource(nil, nil))
+	oldSchema.Resources["my-pkg:index:Removed"] = simpleResource(nil, nil)
+	oldSchema.Resources["my-pkg:index:Overlay"] = overlay
+	oldSchema.Functions = map[string]schema.FunctionSpec{"my-pkg:extra:getRemoved": {}}
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+
+	// Overlays are only grouped on demand.
+	result, err := buildComparison("my-pkg", oldSchema, newSchema, compareOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []summaryItem{{codeMissingResource, 2}, {codeMissingFunction, 1}}, result.summary)
+
+	opts := compareOptions{format: formatJSONLines, overlayPrefixes: []string{"my-pkg:extra:"}, overlayMarked: true}
+	out := new(bytes.Buffer)
+	err = compareSchemas(out, "my-pkg", oldSchema, newSchema, -1, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"path":["Resources","my-pkg:index:Removed"],`+
+		`"code":"MISSING_RESOURCE","severity":"danger","description":"missing"}
+{"path":["Overlay","Functions","my-pkg:extra:getRemoved"],`+
+		`"code":"MISSING_FUNCTION","severity":"danger","description":"missing"}
+{"path":["Overlay","Resources","my-pkg:index:Overlay"],`+
+		`"code":"MISSING_RESOURCE","severity":"danger","description":"missing"}
+`, out.String())
+
+	// Overlays are summarized, and so can fail the comparison, on their own.
+	result, err = buildComparison("my-pkg", oldSchema, newSchema, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, []summaryItem{
+		{codeMissingResource, 1},
+		{"OVERLAY_MISSING_FUNCTION", 1},
+		{"OVERLAY_MISSING_RESOURCE", 1},
+	}, result.summary)
+
+	opts.failOn = []diagtree.Code{parseCode("overlay-missing-function")}
+	err = compareSchemas(new(bytes.Buffer), "my-pkg", oldSchema, newSchema, -1, opts)
+	assert.EqualError(t, err, "found breaking changes in failing categories: OVERLAY_MISSING_FUNCTION (1)")
+
+	// Baselines and allow lists saved without overlay grouping still match.
+	opts.failOn = nil
+	opts.baseline = []jsonDiagnostic{
+		{Path: []string{"Functions", "my-pkg:extra:getRemoved"}, Code: codeMissingFunction},
+	}
+	opts.allowed = []allowance{{Path: []string{"Resources", "my-pkg:index:Overlay"}, Code: codeMissingResource}}
+	result, err = buildComparison("my-pkg", oldSchema, newSchema, opts)
+	assert.NoError(t, err)
+	assert.Empty(t, result.staleAllowances)
+	assert.Equal(t, []jsonDiagnostic{
+		{Path: []string{"Resources", "my-pkg:index:Removed"}, Code: codeMissingResource,
+			Severity: diagtree.Danger, Description: "missing"},
+		{Path: []string{"Overlay", "Resources", "my-pkg:index:Overlay"}, Code: codeMissingResource,
+			Severity: diagtree.Info, Description: "missing (allowed)"},
+	}, jsonDiagnostics(result.violations))
+}
+
+func TestRenderJSONLines(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["removed"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	newSchema := simpleResourceSchema(simpleResource(nil, []string{"value"}))
+
+	out := new(bytes.Buffer)
+	err := compareSchemas(out, "my-pkg", oldSchema, newSchema, -1, compareOptions{format: formatJSONLines})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"path":["Resources","my-pkg:index:MyResource","inputs","removed"],`+
+		`"code":"MISSING_INPUT","severity":"warn","description":"missing"}
+{"path":["Resources","my-pkg:index:MyResource","required inputs","value"],`+
+		`"code":"OPTIONAL_TO_REQUIRED","severity":"info","description":"input has changed to Required"}
+`, out.String())
+}
+
+func TestRenderJSONTree(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["removed"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+
+	out := new(bytes.Buffer)
+	err := compareSchemas(out, "my-pkg", oldSchema, newSchema, -1, compareOptions{format: formatJSONTree})
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "breaking_changes": {
+    "title": "",
+    "children": [
+      {
+        "title": "Resources",
+        "children": [
+          {
+            "title": "\"my-pkg:index:MyResource\"",
+            "children": [
+              {
+                "title": "inputs",
+                "children": [
+                  {
+                    "title": "\"removed\"",
+                    "description": "missing",
+                    "severity": "warn",
+                    "code": "MISSING_INPUT"
+                  }
+                ]
+              }
+            ]
+          }
+        ]
+      }
+    ]
+  },
+  "new_resources": [],
+  "new_functions": [],
+  "churn": {
+    "added": 0,
+    "removed": 1,
+    "retyped": 0
+  },
+  "by_module": [
+    {
+      "module": "index",
+      "count": 1
+    }
+  ]
+}
+`, out.String())
+}
+
+func TestStateInputs(t *testing.T) {
+	withStateInputs := func(props map[string]schema.PropertySpec) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.StateInputs = &schema.ObjectTypeSpec{Type: "object", Properties: props}
+		return simpleResourceSchema(r)
+	}
+	oldSchema := withStateInputs(map[string]schema.PropertySpec{
+		"arn":  {TypeSpec: schema.TypeSpec{Type: "string"}},
+		"size": {TypeSpec: schema.TypeSpec{Type: "integer"}},
+	})
+	newSchema := withStateInputs(map[string]schema.PropertySpec{
+		"size": {TypeSpec: schema.TypeSpec{Type: "string"}},
+	})
+
+	changes := *breakingChanges(oldSchema, newSchema, compareOptions{})
+	expected := expectedRes(func(n *diagtree.Node) {
+		stateInputs := n.Label("state inputs")
+		stateInputs.Value("arn").SetDiagnostic(codeMissingStateInput, diagtree.Warn, "missing")
+		stateInputs.Value("size").SetDiagnostic(codeTypeChanged, diagtree.Warn, `type changed from "integer" to "string"`)
+	})
+	expectedOut, actualOut := new(bytes.Buffer), new(bytes.Buffer)
+	expected.DisplayWith(expectedOut, -1, diagtree.PlainDisplayOptions)
+	changes.DisplayWith(actualOut, -1, diagtree.PlainDisplayOptions)
+	assert.Equal(t, expectedOut.String(), actualOut.String())
+
+	// Resources without state inputs can't be looked up by them.
+	withoutStateInputs := simpleResourceSchema(simpleResource(nil, nil))
+	changes = *breakingChanges(oldSchema, withoutStateInputs, compareOptions{})
+	assert.Equal(t, []jsonDiagnostic{
+		{Path: []string{"Resources", "my-pkg:index:MyResource", "state inputs", "arn"},
+			Code: codeMissingStateInput, Severity: diagtree.Warn, Description: "missing"},
+		{Path: []string{"Resources", "my-pkg:index:MyResource", "state inputs", "size"},
+			Code: codeMissingStateInput, Severity: diagtree.Warn, Description: "missing"},
+	}, jsonDiagnostics(&changes))
+}
+
+func TestObjectShapeChanged(t *testing.T) {
+	str := schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	function := func(inputsType, outputsType string) schema.FunctionSpec {
+		return schema.FunctionSpec{
+			Inputs: &schema.ObjectTypeSpec{
+				Type:       inputsType,
+				Properties: map[string]schema.PropertySpec{"name": str},
+			},
+			Outputs: &schema.ObjectTypeSpec{
+				Type:       outputsType,
+				Properties: map[string]schema.PropertySpec{"arn": str},
+			},
+		}
+	}
+
+	// An omitted type is an object.
+	changes := *breakingChanges(simpleFunctionSchema(function("", "object")),
+		simpleFunctionSchema(function("object", "")), compareOptions{})
+	assert.Equal(t, diagtree.Node{}, changes)
+
+	changes = *breakingChanges(simpleFunctionSchema(function("", "object")),
+		simpleFunctionSchema(function("string", "object")), compareOptions{})
+	assert.Equal(t, expectedFunc(func(n *diagtree.Node) {
+		n.Label("inputs").Label("type").SetDiagnostic(codeObjectShape, diagtree.Warn,
+			`type changed from "object" to "string"`)
+	}), changes)
+}
+
+func TestInputOutputMoved(t *testing.T) {
+	str := schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+
+	t.Run("input to output", func(t *testing.T) {
+		old := simpleResource(nil, nil)
+		old.InputProperties["arn"] = str
+		changed := simpleResource(nil, nil)
+		changed.Properties["arn"] = str
+
+		changes := *breakingChanges(simpleResourceSchema(old), simpleResourceSchema(changed), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("arn").SetDiagnostic(
+				codeInputOutputMoved, diagtree.Info, "moved from inputs to outputs")
+		}), changes)
+	})
+
+	t.Run("output to input", func(t *testing.T) {
+		old := simpleResource(nil, nil)
+		old.Properties["name"] = str
+		changed := simpleResource(nil, nil)
+		changed.InputProperties["name"] = str
+
+		changes := *breakingChanges(simpleResourceSchema(old), simpleResourceSchema(changed), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("properties").Value("name").SetDiagnostic(
+				codeInputOutputMoved, diagtree.Info, "moved from outputs to inputs")
+		}), changes)
+	})
+
+	t.Run("input removed", func(t *testing.T) {
+		// The property was already an output, so only the input is gone.
+		old := simpleResource(nil, nil)
+		old.InputProperties["name"] = str
+		old.Properties["name"] = str
+		changed := simpleResource(nil, nil)
+		changed.Properties["name"] = str
+
+		changes := *breakingChanges(simpleResourceSchema(old), simpleResourceSchema(changed), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("name").SetDiagnostic(codeMissingInput, diagtree.Warn, "missing")
+		}), changes)
+	})
+}
+
+func TestArrayWithoutType(t *testing.T) {
+	withList := func(list schema.TypeSpec) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["list"] = schema.PropertySpec{TypeSpec: list}
+		return simpleResourceSchema(r)
+	}
+	untyped := withList(schema.TypeSpec{Items: &schema.TypeSpec{Type: "number"}})
+
+	t.Run("type added", func(t *testing.T) {
+		typed := withList(schema.TypeSpec{Type: "array", Items: &schema.TypeSpec{Type: "number"}})
+		changes := breakingChanges(untyped, typed, compareOptions{})
+		assert.Equal(t, 0, changes.Display(new(bytes.Buffer), -1))
+	})
+
+	t.Run("items changed", func(t *testing.T) {
+		changed := withList(schema.TypeSpec{Type: "array", Items: &schema.TypeSpec{Type: "string"}})
+		changes := *breakingChanges(untyped, changed, compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("list").Label("items").SetDiagnostic(
+				codeTypeChanged, diagtree.Warn, `type changed from "number" to "string"`)
+		}), changes)
+	})
+}
+
+func TestResourceKindChanged(t *testing.T) {
+	withComponent := func(isComponent bool) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.IsComponent = isComponent
+		return simpleResourceSchema(r)
+	}
+
+	t.Run("to component", func(t *testing.T) {
+		changes := *breakingChanges(withComponent(false), withComponent(true), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("kind").SetDiagnostic(codeResourceKind, diagtree.Danger,
+				"changed from a custom to a component resource")
+		}), changes)
+	})
+
+	t.Run("to custom", func(t *testing.T) {
+		changes := *breakingChanges(withComponent(true), withComponent(false), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("kind").SetDiagnostic(codeResourceKind, diagtree.Danger,
+				"changed from a component to a custom resource")
+		}), changes)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		changes := breakingChanges(withComponent(true), withComponent(true), compareOptions{})
+		assert.Equal(t, 0, changes.Display(new(bytes.Buffer), -1))
+	})
+}
+
+func TestReplaceOnChanges(t *testing.T) {
+	withReplace := func(replace, willReplace bool) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["name"] = schema.PropertySpec{
+			TypeSpec:             schema.TypeSpec{Type: "string"},
+			ReplaceOnChanges:     replace,
+			WillReplaceOnChanges: willReplace,
+		}
+		return simpleResourceSchema(r)
+	}
+
+	t.Run("added", func(t *testing.T) {
+		changes := *breakingChanges(withReplace(false, false), withReplace(true, false), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("replace on changes").Value("name").SetDiagnostic(
+				codeReplaceOnChanges, diagtree.Info, "changing this input now replaces the resource")
+		}), changes)
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		changes := *breakingChanges(withReplace(false, true), withReplace(false, false), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("replace on changes").Value("name").SetDiagnostic(
+				codeReplaceOnChanges, diagtree.Info, "changing this input no longer replaces the resource")
+		}), changes)
+	})
+
+	t.Run("new input", func(t *testing.T) {
+		oldSchema := simpleResourceSchema(simpleResource(nil, nil))
+		changes := breakingChanges(oldSchema, withReplace(true, false), compareOptions{})
+		assert.Equal(t, 0, changes.Display(new(bytes.Buffer), -1))
+	})
+}
+
+func TestProviderConfig(t *testing.T) {
+	str := schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleEmptySchema()
+	oldSchema.Config = schema.ConfigSpec{
+		Variables: map[string]schema.PropertySpec{"region": str, "profile": str},
+	}
+	oldSchema.Provider = schema.ResourceSpec{
+		InputProperties: map[string]schema.PropertySpec{"region": str, "profile": str},
+	}
+
+	newSchema := simpleEmptySchema()
+	newSchema.Config = schema.ConfigSpec{
+		Variables: map[string]schema.PropertySpec{
+			"region": {TypeSpec: schema.TypeSpec{Type: "integer"}},
+		},
+		Required: []string{"region"},
+	}
+	newSchema.Provider = schema.ResourceSpec{
+		InputProperties: map[string]schema.PropertySpec{"region": str},
+		RequiredInputs:  []string{"region"},
+	}
+
+	expected := new(diagtree.Node)
+	config := expected.Label("Config")
+	config.Value("profile").SetDiagnostic(codeMissingConfig, diagtree.Warn, "missing")
+	config.Value("region").SetDiagnostic(codeTypeChanged, diagtree.Warn, `type changed from "string" to "integer"`)
+	config.Label("required").Value("region").SetDiagnostic(
+		codeOptionalToRequired, diagtree.Info, "config has changed to Required")
+	provider := expected.Label("Provider")
+	provider.Label("inputs").Value("profile").SetDiagnostic(codeMissingInput, diagtree.Warn, "missing")
+	provider.Label("required inputs").Value("region").SetDiagnostic(
+		codeOptionalToRequired, diagtree.Info, "input has changed to Required")
+
+	actual, want := new(bytes.Buffer), new(bytes.Buffer)
+	expected.Display(want, -1)
+	breakingChanges(oldSchema, newSchema, compareOptions{}).Display(actual, -1)
+	assert.Equal(t, want.String(), actual.String())
+}
+
+func TestRenderSummary(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["a"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	old.InputProperties["b"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	oldSchema.Resources["my-pkg:index:Removed"] = simpleResource(nil, nil)
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+
+	t.Run("text", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		err := compareSchemas(out, "my-pkg", oldSchema, newSchema, -1,
+			compareOptions{format: formatText, summary: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "### Does the PR have any schema changes?\n\n"+
+			"Found 3 breaking changes (1 danger, 2 warn):\n"+
+			"- `MISSING_INPUT`: 2\n"+
+			"- `MISSING_RESOURCE`: 1\n"+
+			"No new resources/functions.\n"+
+			"\n"+
+			"Property churn: 0 added, 6 removed, 0 retyped.\n", out.String())
+	})
+
+	t.Run("json-lines", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		err := compareSchemas(out, "my-pkg", oldSchema, newSchema, -1,
+			compareOptions{format: formatJSONLines, summary: true})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"code":"MISSING_INPUT","count":2}
+{"code":"MISSING_RESOURCE","count":1}
+`, out.String())
+	})
+
+	t.Run("include new", func(t *testing.T) {
+		newSchema := simpleResourceSchema(simpleResource(nil, nil))
+		newSchema.Resources["my-pkg:index:Added"] = simpleResource(nil, nil)
+		newSchema.Resources["my-pkg:index:AlsoAdded"] = simpleResource(nil, nil)
+		newSchema.Functions = map[string]schema.FunctionSpec{"my-pkg:index:getAdded": {}}
+
+		// New resources and functions are ordered by their number like the breaking changes.
+		out := new(bytes.Buffer)
+		err := compareSchemas(out, "my-pkg", oldSchema, newSchema, -1,
+			compareOptions{format: formatJSONLines, summary: true, includeNewInSummary: true})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"code":"MISSING_INPUT","count":2}
+{"code":"NEW_RESOURCE","count":2}
+{"code":"MISSING_RESOURCE","count":1}
+{"code":"NEW_FUNCTION","count":1}
+`, out.String())
+	})
+}
+
+func TestRenderGroupByToken(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["a"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	old.InputProperties["b"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	oldSchema.Resources["my-pkg:index:Removed"] = simpleResource(nil, nil)
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+
+	out := new(bytes.Buffer)
+	err := compareSchemas(out, "my-pkg", oldSchema, newSchema, -1,
+		compareOptions{format: formatPlain, groupBy: groupByToken})
+	assert.NoError(t, err)
+	assert.Equal(t, "Does the PR have any schema changes?\n\n"+
+		"Found 3 breaking changes (1 danger, 2 warn):\n"+
+		"\n"+
+		"index.MyResource (2)\n"+
+		"* inputs: a missing\n"+
+		"* inputs: b missing\n"+
+		"\n"+
+		"index.Removed (1)\n"+
+		"* missing\n"+
+		"\n"+
+		"No new resources/functions.\n"+
+		"\n"+
+		"Property churn: 0 added, 6 removed, 0 retyped.\n", out.String())
+}
+
+func TestRenderGitHub(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["removed"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+	newSchema.Resources["my-pkg:index:Other"] = simpleResource(nil, nil)
+
+	out := new(bytes.Buffer)
+	err := compareSchemas(out, "my-pkg", oldSchema, newSchema, -1, compareOptions{format: formatGitHub})
+	assert.NoError(t, err)
+	assert.Equal(t, "### Does the PR have any schema changes?\n\n"+
+		"<details>\n"+
+		"<summary>Found 1 breaking change (1 warn)</summary>\n"+
+		"\n"+
+		"#### Resources\n"+
+		"- `🟡` \"my-pkg:index:MyResource\": inputs: \"removed\" missing\n"+
+		"\n"+
+		"</details>\n"+
+		"\n"+
+		"#### New resources:\n"+
+		"\n"+
+		"- `index.Other`\n"+
+		"\n"+
+		"Property churn: 4 added, 1 removed, 0 retyped.\n", out.String())
+}
+
+func TestBaseline(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["known"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	old.InputProperties["fresh"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+
+	baselineFile := filepath.Join(t.TempDir(), "baseline.json")
+	err := os.WriteFile(baselineFile, []byte(`{
+  "breaking_changes": [
+    {
+      "path": ["Resources", "my-pkg:index:MyResource", "inputs", "known"],
+      "code": "MISSING_INPUT",
+      "severity": "warn",
+      "description": "an older wording of the message"
+    }
+  ],
+  "new_resources": [],
+  "new_functions": []
+}`), 0o600)
+	assert.NoError(t, err)
+	baseline, err := loadBaseline(baselineFile)
+	assert.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	err = compareSchemas(out, "my-pkg", oldSchema, newSchema, -1,
+		compareOptions{format: formatJSONLines, baseline: baseline})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"path":["Resources","my-pkg:index:MyResource","inputs","fresh"],`+
+		`"code":"MISSING_INPUT","severity":"warn","description":"missing"}
+`, out.String())
+
+	notAReport := filepath.Join(t.TempDir(), "baseline.json")
+	assert.NoError(t, os.WriteFile(notAReport, []byte(`{"path":["Resources"],"code":"MISSING_RESOURCE"}`), 0o600))
+	_, err = loadBaseline(notAReport)
+	assert.EqualError(t, err,
+		`baseline `+notAReport+` has no "breaking_changes", expected a report from --format json`)
+}
+
+func TestAllowances(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["approved"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	old.InputProperties["unexpected"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+
+	allowFile := filepath.Join(t.TempDir(), "allow.yaml")
+	err := os.WriteFile(allowFile, []byte(`
+- path: [Resources, "my-pkg:index:MyResource", inputs, approved]
+  code: MISSING_INPUT
+  reason: deprecated since v2
+- path: [Resources, "my-pkg:index:MyResource", inputs, approved]
+  code: MISSING_INPUT
+  reason: listed twice, which doesn't make the second entry stale
+- path: [Resources, "my-pkg:index:MyResource", inputs, long-gone]
+  code: missing-input
+`), 0o600)
+	assert.NoError(t, err)
+	allowed, err := loadAllowances(allowFile)
+	assert.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	err = compareSchemas(out, "my-pkg", oldSchema, newSchema, -1, compareOptions{format: formatJSON, allowed: allowed})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+  "breaking_changes": [
+    {
+      "path": ["Resources", "my-pkg:index:MyResource", "inputs", "approved"],
+      "code": "MISSING_INPUT",
+      "severity": "info",
+      "description": "missing (allowed: deprecated since v2)"
+    },
+    {
+      "path": ["Resources", "my-pkg:index:MyResource", "inputs", "unexpected"],
+      "code": "MISSING_INPUT",
+      "severity": "warn",
+      "description": "missing"
+    }
+  ],
+  "new_resources": [],
+  "new_functions": [],
+  "churn": {"added": 0, "removed": 2, "retyped": 0},
+  "by_module": [{"module": "index", "count": 2}],
+  "stale_allowances": [
+    {"path": ["Resources", "my-pkg:index:MyResource", "inputs", "long-gone"], "code": "MISSING_INPUT"}
+  ]
+}`, out.String())
+
+	// Unknown codes would never match, so they are rejected rather than reported as stale.
+	err = os.WriteFile(allowFile, []byte(`
+- path: [Resources, "my-pkg:index:MyResource", inputs, approved]
+  code: MISSING_INPUT
+- path: [Resources, "my-pkg:index:MyResource", inputs, approved]
+  code: missing-inputs
+`), 0o600)
+	assert.NoError(t, err)
+	_, err = loadAllowances(allowFile)
+	assert.EqualError(t, err, "allow list "+allowFile+`: entry 1 has unknown code "missing-inputs"`)
+}
+
+func TestScalarObjectChanges(t *testing.T) {
+	withInput := func(ts schema.TypeSpec) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["config"] = schema.PropertySpec{TypeSpec: ts}
+		sch := simpleResourceSchema(r)
+		sch.Types = map[string]schema.ComplexTypeSpec{
+			"my-pkg:index:Config": {ObjectTypeSpec: schema.ObjectTypeSpec{Type: "object"}},
+		}
+		return sch
+	}
+	scalar := withInput(schema.TypeSpec{Type: "string"})
+	object := withInput(schema.TypeSpec{Ref: "#/types/my-pkg:index:Config"})
+
+	t.Run("scalar to object", func(t *testing.T) {
+		changes := *breakingChanges(scalar, object, compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("config").SetDiagnostic(codeScalarToObject, diagtree.Warn,
+				`type changed from scalar "string" to object "#/types/my-pkg:index:Config"`)
+		}), changes)
+	})
+
+	t.Run("object to scalar", func(t *testing.T) {
+		changes := *breakingChanges(object, scalar, compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("config").SetDiagnostic(codeObjectToScalar, diagtree.Warn,
+				`type changed from object "#/types/my-pkg:index:Config" to scalar "string"`)
+		}), changes)
+	})
+}
+
+func TestUnionChanges(t *testing.T) {
+	withPet := func(ts schema.TypeSpec) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["pet"] = schema.PropertySpec{TypeSpec: ts}
+		return simpleResourceSchema(r)
+	}
+	cat, dog := schema.TypeSpec{Ref: "#/types/my-pkg:index:Cat"}, schema.TypeSpec{Ref: "#/types/my-pkg:index:Dog"}
+	pets := func(mapping map[string]string, cases ...schema.TypeSpec) schema.PackageSpec {
+		return withPet(schema.TypeSpec{
+			OneOf:         cases,
+			Discriminator: &schema.DiscriminatorSpec{PropertyName: "kind", Mapping: mapping},
+		})
+	}
+
+	t.Run("case removed", func(t *testing.T) {
+		mapping := map[string]string{"cat": cat.Ref}
+		changes := *breakingChanges(pets(mapping, cat, dog), pets(mapping, cat), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("pet").Label("one of").Value(dog.Ref).SetDiagnostic(
+				codeUnionCaseRemoved, diagtree.Warn, "union case removed")
+		}), changes)
+	})
+
+	t.Run("plain type to union", func(t *testing.T) {
+		changes := *breakingChanges(withPet(cat), withPet(schema.TypeSpec{OneOf: []schema.TypeSpec{dog, cat}}),
+			compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("pet").Label("one of").Value(dog.Ref).SetDiagnostic(
+				codeUnionCaseAdded, diagtree.Info, "union case added")
+		}), changes)
+	})
+
+	t.Run("discriminator mapping changed", func(t *testing.T) {
+		changes := breakingChanges(
+			pets(map[string]string{"cat": cat.Ref, "dog": dog.Ref}, cat, dog),
+			pets(map[string]string{"cat": dog.Ref}, cat, dog),
+			compareOptions{})
+		expected := expectedRes(func(n *diagtree.Node) {
+			mapping := n.Label("inputs").Value("pet").Label("discriminator").Label("mapping")
+			mapping.Value("cat").SetDiagnostic(codeDiscriminator, diagtree.Warn,
+				`maps to "#/types/my-pkg:index:Dog" instead of "#/types/my-pkg:index:Cat"`)
+			mapping.Value("dog").SetDiagnostic(codeDiscriminator, diagtree.Warn,
+				`no longer maps to "#/types/my-pkg:index:Dog"`)
+		})
+
+		// The mapping is visited in map order, so we compare the sorted display.
+		want, actual := new(bytes.Buffer), new(bytes.Buffer)
+		assert.Equal(t, 2, expected.Display(want, -1))
+		changes.Display(actual, -1)
+		assert.Equal(t, want.String(), actual.String())
+	})
+}
+
+func TestNewRequiredInputWithDefault(t *testing.T) {
+	withDefault := func(required []string) schema.PackageSpec {
+		r := simpleResource(nil, required)
+		r.InputProperties["value"] = schema.PropertySpec{
+			TypeSpec: schema.TypeSpec{Type: "string"},
+			Default:  "a default",
+		}
+		return simpleResourceSchema(r)
+	}
+
+	changes := breakingChanges(withDefault(nil), withDefault([]string{"value"}), compareOptions{})
+	// Still reported, but under its own code so that it can be left out of --fail-on.
+	assert.Equal(t, []jsonDiagnostic{
+		{Path: []string{"Resources", "my-pkg:index:MyResource", "required inputs", "value"},
+			Code: codeRequiredWithDefault, Severity: diagtree.Info,
+			Description: "input has changed to Required, with a default"},
+	}, jsonDiagnostics(changes))
+}
+
+func TestDefaultChanges(t *testing.T) {
+	withPort := func(def any) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["port"] = schema.PropertySpec{
+			TypeSpec: schema.TypeSpec{Type: "integer"},
+			Default:  def,
+		}
+		return simpleResourceSchema(r)
+	}
+
+	t.Run("changed", func(t *testing.T) {
+		changes := *breakingChanges(withPort(443), withPort(80), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("port").Label("default").SetDiagnostic(
+				codeDefaultChanged, diagtree.Info, "default changed from 443 to 80")
+		}), changes)
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		changes := *breakingChanges(withPort(443), withPort(nil), compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("port").Label("default").SetDiagnostic(
+				codeDefaultChanged, diagtree.Info, "default 443 removed")
+		}), changes)
+	})
+
+	t.Run("int and float", func(t *testing.T) {
+		changes := breakingChanges(withPort(7), withPort(7.0), compareOptions{})
+		assert.Equal(t, 0, changes.Display(new(bytes.Buffer), -1))
+	})
+}
+
+func TestDefaultEnvironmentChanges(t *testing.T) {
+	withEnv := func(env ...string) schema.PackageSpec {
+		prop := schema.PropertySpec{
+			TypeSpec:    schema.TypeSpec{Type: "string"},
+			DefaultInfo: &schema.DefaultSpec{Environment: env},
+		}
+		r := simpleResource(nil, nil)
+		r.InputProperties["region"] = prop
+		sch := simpleResourceSchema(r)
+		sch.Config.Variables = map[string]schema.PropertySpec{"region": prop}
+		return sch
+	}
+
+	changes := breakingChanges(withEnv("AWS_REGION", "AWS_DEFAULT_REGION"), withEnv("AWS_REGION", "AWS_REGION_NAME"),
+		compareOptions{})
+	out := new(bytes.Buffer)
+	changes.DisplayWith(out, -1, diagtree.PlainDisplayOptions)
+	assert.Equal(t, `
+Resources
+* "my-pkg:index:MyResource": inputs: "region": default environment:
+  * "AWS_DEFAULT_REGION" no longer read
+  * "AWS_REGION_NAME" now read
+Config
+* "region": default environment:
+  * "AWS_DEFAULT_REGION" no longer read
+  * "AWS_REGION_NAME" now read
+`, out.String())
+
+	changes = breakingChanges(withEnv("AWS_REGION"), withEnv("AWS_REGION"), compareOptions{})
+	assert.Equal(t, 0, changes.Display(new(bytes.Buffer), -1))
+}
+
+func TestRenderHTML(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["<script>"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	oldSchema.Resources["my-pkg:index:Removed"] = simpleResource(nil, nil)
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+
+	out := new(bytes.Buffer)
+	err := compareSchemas(out, "my-pkg", oldSchema, newSchema, -1, compareOptions{format: formatHTML})
+	assert.NoError(t, err)
+
+	html := out.String()
+	assert.Contains(t, html, "<p>Found 2 breaking changes.</p>")
+	assert.Contains(t, html, "<summary>MISSING_INPUT (1)</summary>")
+	assert.Contains(t, html, "<summary>MISSING_RESOURCE (1)</summary>")
+	assert.Contains(t, html, `<td class="severity danger">danger</td>`)
+	// Titles from the schema must be escaped.
+	assert.Contains(t, html, "inputs / &lt;script&gt;")
+	assert.NotContains(t, html, "<script>")
+}
+
+func TestCountChurn(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["removed"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+
+	changed := simpleResource(nil, nil)
+	changed.InputProperties["value"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "integer"}}
+	changed.InputProperties["added"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	newSchema := simpleResourceSchema(changed)
+
+	assert.Equal(t, churnStats{Added: 1, Removed: 1, Retyped: 1}, countChurn(oldSchema, newSchema))
+}
+
+func TestFormatName(t *testing.T) {
+	assert.Equal(t, "ec2/instance.Instance", formatName("aws", "aws:ec2/instance:Instance"))
+	assert.Equal(t, "aws:ec2/instance.Instance", formatName("awsx", "aws:ec2/instance:Instance"))
+	assert.Equal(t, "index.Thing", formatName("aws", "aws:index:Thing"))
+	assert.Equal(t, "not-a-token", formatName("aws", "not-a-token"))
+}
+
+func TestTruncationWarning(t *testing.T) {
+	oldSchema := simpleResourceSchema(simpleResource(nil, nil))
+	for _, token := range []string{"my-pkg:index:A", "my-pkg:index:B", "my-pkg:index:C"} {
+		oldSchema.Resources[token] = simpleResource(nil, nil)
+	}
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+
+	t.Run("warn", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		err := compareSchemas(out, "my-pkg", oldSchema, newSchema, 0,
+			compareOptions{format: formatText, truncationThreshold: 0.5})
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(out.String(), "> [!WARNING]\n"+
+			"> the new schema appears truncated: it has 1 resources and functions, down from 4\n\n"+
+			"### Does the PR have any schema changes?"), out.String())
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		err := compareSchemas(new(bytes.Buffer), "my-pkg", oldSchema, newSchema, 0,
+			compareOptions{format: formatText, truncationThreshold: 0.5, failOnTruncation: true})
+		assert.EqualError(t, err, "the new schema appears truncated: it has 1 resources and functions, down from 4")
+		assert.Equal(t, exitThreshold, exitCode(err))
+	})
+
+	t.Run("above threshold", func(t *testing.T) {
+		assert.Empty(t, truncationWarning(oldSchema, newSchema, compareOptions{truncationThreshold: 0.25}))
+	})
+
+	t.Run("out of scope", func(t *testing.T) {
+		opts := compareOptions{truncationThreshold: 0.5, scopes: []string{scopeFunctions}}
+		assert.Empty(t, truncationWarning(oldSchema, newSchema, opts))
+		opts.scopes = []string{scopeResources}
+		assert.Equal(t, "the new schema appears truncated: it has 1 resources, down from 4",
+			truncationWarning(oldSchema, newSchema, opts))
+	})
+}
+
+func TestPackageNameWarning(t *testing.T) {
+	oldSchema := simpleResourceSchema(simpleResource(nil, nil))
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+	newSchema.Name = "other-pkg"
+
+	result, err := buildComparison("my-pkg", oldSchema, newSchema, compareOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`the schemas are for different packages: "my-pkg" and "other-pkg"`}, result.warnings)
+
+	_, err = buildComparison("my-pkg", oldSchema, newSchema, compareOptions{strictName: true})
+	assert.EqualError(t, err, `the schemas are for different packages: "my-pkg" and "other-pkg"`)
+	assert.Equal(t, exitThreshold, exitCode(err))
+
+	// The patch format doesn't compare the schemas, but is still for the same package.
+	out := new(bytes.Buffer)
+	err = compareSchemas(out, "my-pkg", oldSchema, newSchema, -1,
+		compareOptions{format: formatJSONPatch, strictName: true})
+	assert.EqualError(t, err, `the schemas are for different packages: "my-pkg" and "other-pkg"`)
+	assert.Empty(t, out.String())
+
+	// Formats without room for warnings print them separately.
+	for _, opts := range []compareOptions{{format: formatJSONLines}, {format: formatJSONLines, stream: true}} {
+		errOut := new(bytes.Buffer)
+		opts.errOut = errOut
+		assert.NoError(t, compareSchemas(new(bytes.Buffer), "my-pkg", oldSchema, newSchema, -1, opts))
+		assert.Equal(t, "warning: the schemas are for different packages: \"my-pkg\" and \"other-pkg\"\n",
+			errOut.String())
+	}
+}
+
+func TestRenderPlain(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["removed"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+	newSchema.Resources["my-pkg:index:Other"] = simpleResource(nil, nil)
+
+	out := new(bytes.Buffer)
+	err := compareSchemas(out, "my-pkg", oldSchema, newSchema, -1, compareOptions{format: formatPlain})
+	assert.NoError(t, err)
+	assert.Equal(t, `Does the PR have any schema changes?
+
+Found 1 breaking change (1 warn): 
+
+Resources
+* "my-pkg:index:MyResource": inputs: "removed" missing
+
+New resources:
+
+* index.Other
+
+Property churn: 4 added, 1 removed, 0 retyped.
+`, out.String())
+}
+
+func TestBreakingChangesByModule(t *testing.T) {
+	oldSchema := simpleResourceSchema(simpleResource(nil, nil))
+	oldSchema.Resources["my-pkg:s3/bucket:Bucket"] = simpleResource(nil, nil)
+	oldSchema.Resources["my-pkg:s3/object:Object"] = simpleResource(nil, nil)
+	oldSchema.Functions = map[string]schema.FunctionSpec{"my-pkg:ec2/getVpc:getVpc": {}}
+	oldSchema.Config.Variables = map[string]schema.PropertySpec{"region": {}}
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+
+	out := new(bytes.Buffer)
+	err := compareSchemas(out, "my-pkg", oldSchema, newSchema, -1, compareOptions{format: formatPlain, summary: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `Does the PR have any schema changes?
+
+Found 4 breaking changes (3 danger, 1 warn):
+* MISSING_RESOURCE: 2
+* MISSING_CONFIG: 1
+* MISSING_FUNCTION: 1
+
+Breaking changes by module:
+
+* s3: 2
+* ec2: 1
+No new resources/functions.
+
+Property churn: 0 added, 8 removed, 0 retyped.
+`, out.String())
+}
+
+func TestCompareOutFile(t *testing.T) {
+	oldSchema := simpleResourceSchema(simpleResource(nil, nil))
+	for _, name := range []string{"A", "B", "C", "D"} {
+		r := simpleResource(nil, nil)
+		r.InputProperties["removed"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+		oldSchema.Resources["my-pkg:index:"+name] = r
+		oldSchema.Resources["my-pkg:mod"+name+":"+name] = r
+	}
+	oldSchema.Config.Variables = map[string]schema.PropertySpec{"a": {}, "b": {}}
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+	for _, name := range []string{"A", "B", "C", "D"} {
+		newSchema.Resources["my-pkg:index:"+name] = simpleResource(nil, nil)
+	}
+
+	dir := t.TempDir()
+	writeSchema := func(name string, sch schema.PackageSpec) string {
+		data, err := json.Marshal(sch)
+		assert.NoError(t, err)
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, data, 0o600))
+		return path
+	}
+	oldPath, newPath := writeSchema("old.json", oldSchema), writeSchema("new.json", newSchema)
+
+	// The report only depends on the schemas, whatever the order maps are iterated in.
+	var golden []byte
+	for i := 0; i < 10; i++ {
+		outPath := filepath.Join(dir, "report.md")
+		command := compareCmd()
+		command.SetArgs([]string{"-p", "my-pkg", "--old-commit=--local-path=" + oldPath,
+			"--new-commit=--local-path=" + newPath, "--out", outPath})
+		assert.NoError(t, command.Execute())
+
+		report, err := os.ReadFile(outPath)
+		assert.NoError(t, err)
+		if golden == nil {
+			golden = report
+			assert.Contains(t, string(report), "Found 10 breaking changes")
+		}
+		assert.Equal(t, string(golden), string(report))
+	}
+}
+
+func TestProvidersWithPackage(t *testing.T) {
+	command := compareCmd()
+	command.SetArgs([]string{"--providers", "aws,gcp", "--old-commit", "master", "--new-package", "aws@6.0.0"})
+	command.SilenceErrors, command.SilenceUsage = true, true
+	assert.EqualError(t, command.Execute(), `"old-package" and "new-package" cannot be used with "providers"`)
+}
+
+func TestPlainnessChanged(t *testing.T) {
+	withTags := func(plain bool) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["tags"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{
+			Type:  "array",
+			Items: &schema.TypeSpec{Type: "string", Plain: plain},
+		}}
+		return simpleResourceSchema(r)
+	}
+
+	changes := *breakingChanges(withTags(false), withTags(true), compareOptions{})
+	assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+		n.Label("inputs").Value("tags").Label("items").Label("plain").SetDiagnostic(
+			codePlainness, diagtree.Info, "changed from an Output to a plain value")
+	}), changes)
+
+	changes = *breakingChanges(withTags(true), withTags(false), compareOptions{})
+	assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+		n.Label("inputs").Value("tags").Label("items").Label("plain").SetDiagnostic(
+			codePlainness, diagtree.Info, "changed from a plain value to an Output")
+	}), changes)
+}
+
+func TestMapValueTypeChanges(t *testing.T) {
+	withMap := func(values *schema.TypeSpec) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["tags"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{
+			Type:                 "object",
+			AdditionalProperties: values,
+		}}
+		return simpleResourceSchema(r)
+	}
+	typed, untyped := withMap(&schema.TypeSpec{Type: "string"}), withMap(nil)
+
+	t.Run("typed to untyped", func(t *testing.T) {
+		changes := *breakingChanges(typed, untyped, compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("tags").Label("additional properties").SetDiagnostic(
+				codeTypeChanged, diagtree.Warn, `map value type was "string", now untyped`)
+		}), changes)
+	})
+
+	t.Run("untyped to typed", func(t *testing.T) {
+		changes := *breakingChanges(untyped, typed, compareOptions{})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("tags").Label("additional properties").SetDiagnostic(
+				codeTypeNarrowed, diagtree.Warn, `map value type was untyped, now "string"`)
+		}), changes)
+	})
+}
+
+func TestLoadSchemas(t *testing.T) {
+	local := func(path string) schemaSource {
+		return schemaSource{commit: "--local-path=" + path}
+	}
+	fixture := filepath.Join("..", "pkg", "schema.json")
+
+	old, new, err := loadSchemas(context.Background(), "test", local(fixture), local(fixture))
+	assert.NoError(t, err)
+	assert.Equal(t, "test", old.Name)
+	assert.Equal(t, "test", new.Name)
+
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	_, _, err = loadSchemas(context.Background(), "test", local(missing), local(missing))
+	assert.ErrorContains(t, err, "loading the old schema (--local-path="+missing+")")
+	assert.ErrorContains(t, err, "loading the new schema (--local-path="+missing+")")
+}
+
+func TestCompareProviders(t *testing.T) {
+	local := func(path string) schemaSource {
+		return schemaSource{commit: "--local-path=" + path}
+	}
+	fixture := local(filepath.Join("..", "pkg", "schema.json"))
+	opts := compareOptions{format: formatPlain, groupBy: groupByKind}
+
+	out := new(bytes.Buffer)
+	err := compareProviders(out, []string{"aws", "gcp"}, fixture, fixture, 500, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "== aws\n\n")
+	assert.Contains(t, out.String(), "== gcp\n\n")
+	assert.True(t, strings.HasSuffix(out.String(), `== Summary
+
+* aws: no breaking changes
+* gcp: no breaking changes
+`), out.String())
+
+	out.Reset()
+	missing := local(filepath.Join(t.TempDir(), "missing.json"))
+	err = compareProviders(out, []string{"aws", "gcp"}, missing, fixture, 500, opts)
+	assert.EqualError(t, err, "2 of 2 providers could not be compared")
+	assert.Contains(t, out.String(), "* aws: failed\n* gcp: failed\n")
+}
+
+func TestRenderJSONPatch(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["removed"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	old.RequiredInputs = []string{"value", "removed"}
+	oldSchema := simpleResourceSchema(old)
+
+	changed := simpleResource(nil, nil)
+	changed.InputProperties["value"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "integer"}}
+	changed.InputProperties["a/b"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	newSchema := simpleResourceSchema(changed)
+
+	out := new(bytes.Buffer)
+	err := compareSchemas(out, "my-pkg", oldSchema, newSchema, -1, compareOptions{format: formatJSONPatch})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+  {"op": "add", "path": "/resources/my-pkg:index:MyResource/inputProperties/a~1b", "value": {"type": "string"}},
+  {"op": "remove", "path": "/resources/my-pkg:index:MyResource/inputProperties/removed"},
+  {"op": "replace", "path": "/resources/my-pkg:index:MyResource/inputProperties/value/type", "value": "integer"},
+  {"op": "remove", "path": "/resources/my-pkg:index:MyResource/requiredInputs"}
+]`, out.String())
+
+	out.Reset()
+	err = compareSchemas(out, "my-pkg", oldSchema, oldSchema, -1, compareOptions{format: formatJSONPatch})
+	assert.NoError(t, err)
+	assert.Equal(t, "[]\n", out.String())
+}
+
+func TestJSONPatchArrays(t *testing.T) {
+	patch, err := jsonPatch([]string{"a", "b", "c"}, []string{"x"})
+	assert.NoError(t, err)
+	assert.Equal(t, []jsonPatchOp{
+		{Op: "replace", Path: "/0", Value: json.RawMessage(`"x"`)},
+		{Op: "remove", Path: "/2"},
+		{Op: "remove", Path: "/1"},
+	}, patch)
+
+	patch, err = jsonPatch([]any{"a"}, []any{"a", nil})
+	assert.NoError(t, err)
+	assert.Equal(t, []jsonPatchOp{{Op: "add", Path: "/1", Value: json.RawMessage(`null`)}}, patch)
+}
+
+func TestFailOnCategory(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["removed"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	oldSchema.Resources["my-pkg:index:Removed"] = simpleResource(nil, nil)
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+
+	assert.Equal(t, codeMissingResource, parseCode(" missing-resource"))
+
+	compare := func(categories ...string) error {
+		opts := compareOptions{format: formatJSON}
+		for _, c := range categories {
+			opts.failOn = append(opts.failOn, parseCode(c))
+		}
+		return compareSchemas(new(bytes.Buffer), "my-pkg", oldSchema, newSchema, -1, opts)
+	}
+	assert.NoError(t, compare())
+	assert.NoError(t, compare("type-changed"))
+	err := compare("missing-resource", "MISSING_INPUT", "missing-function")
+	assert.EqualError(t, err,
+		"found breaking changes in failing categories: MISSING_INPUT (1), MISSING_RESOURCE (1)")
+
+	// Approved changes don't fail the comparison, though they are still reported.
+	opts := compareOptions{
+		format:  formatJSON,
+		failOn:  []diagtree.Code{codeMissingResource},
+		allowed: []allowance{{Path: []string{"Resources", "my-pkg:index:Removed"}, Code: codeMissingResource}},
+	}
+	assert.NoError(t, compareSchemas(new(bytes.Buffer), "my-pkg", oldSchema, newSchema, -1, opts))
+	opts.allowed = nil
+	assert.Error(t, compareSchemas(new(bytes.Buffer), "my-pkg", oldSchema, newSchema, -1, opts))
+
+	// Failing categories exit with their own code, so CI can tell them from a failed run.
+	assert.Equal(t, exitThreshold, exitCode(err))
+	assert.Equal(t, exitError, exitCode(errors.New("downloading schema: 404")))
+}
+
+func TestCommentAPIHost(t *testing.T) {
+	assert.Equal(t, "api.github.com", commentAPIHost("github://api.github.com/pulumi"))
+	assert.Equal(t, "ghe.mycorp.com", commentAPIHost("github://ghe.mycorp.com/pulumi/pulumi-aws"))
+	assert.Equal(t, "ghe.mycorp.com/api/v3", commentAPIHost("github://ghe.mycorp.com/api/v3/pulumi"))
+	assert.Equal(t, "api.github.com", commentAPIHost("gitlab://gitlab.com/pulumi"))
+}
+
+func TestStreamComparison(t *testing.T) {
+	old := simpleResource(nil, nil)
+	old.InputProperties["removed"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	oldSchema := simpleResourceSchema(old)
+	oldSchema.Resources["my-pkg:index:Removed"] = simpleResource(nil, nil)
+	oldSchema.Functions = map[string]schema.FunctionSpec{"my-pkg:index:getThing": {}}
+	newSchema := simpleResourceSchema(simpleResource(nil, nil))
+
+	// Streaming reports the same breaking changes as a full comparison, ordered by token.
+	opts := compareOptions{format: formatJSONLines}
+	full := new(bytes.Buffer)
+	err := compareSchemas(full, "my-pkg", oldSchema, newSchema, -1, opts)
+	assert.NoError(t, err)
+
+	opts.stream = true
+	streamed := new(bytes.Buffer)
+	err = compareSchemas(streamed, "my-pkg", oldSchema, newSchema, -1, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, full.String(), streamed.String())
+	assert.Equal(t, `{"path":["Resources","my-pkg:index:MyResource","inputs","removed"],"code":"MISSING_INPUT","severity":"warn","description":"missing"}
+{"path":["Resources","my-pkg:index:Removed"],"code":"MISSING_RESOURCE","severity":"danger","description":"missing"}
+{"path":["Functions","my-pkg:index:getThing"],"code":"MISSING_FUNCTION","severity":"danger","description":"missing"}
+`, streamed.String())
+
+	opts.baseline = jsonDiagnostics(breakingChanges(oldSchema, newSchema, compareOptions{}))[:2]
+	opts.failOn = []diagtree.Code{codeMissingInput, codeMissingFunction}
+	streamed.Reset()
+	err = compareSchemas(streamed, "my-pkg", oldSchema, newSchema, -1, opts)
+	assert.EqualError(t, err, "found breaking changes in failing categories: MISSING_FUNCTION (1)")
+	assert.Equal(t, `{"path":["Functions","my-pkg:index:getThing"],"code":"MISSING_FUNCTION","severity":"danger","description":"missing"}
+`, streamed.String())
+}
+
+func TestCheckDescriptions(t *testing.T) {
+	withDescription := func(description string) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["name"] = schema.PropertySpec{
+			TypeSpec:    schema.TypeSpec{Type: "string"},
+			Description: description,
+		}
+		return simpleResourceSchema(r)
+	}
+
+	t.Run("removed", func(t *testing.T) {
+		changes := *breakingChanges(withDescription("The name."), withDescription(""),
+			compareOptions{checkDescriptions: true})
+		assert.Equal(t, expectedRes(func(n *diagtree.Node) {
+			n.Label("inputs").Value("name").Label("description").SetDiagnostic(
+				codeDescriptionRemoved, diagtree.Info, "description removed")
+		}), changes)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		changes := breakingChanges(withDescription("The name."), withDescription("A name."),
+			compareOptions{checkDescriptions: true})
+		assert.Equal(t, 0, changes.Display(new(bytes.Buffer), -1))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		changes := breakingChanges(withDescription("The name."), withDescription(""), compareOptions{})
+		assert.Equal(t, 0, changes.Display(new(bytes.Buffer), -1))
+	})
+}
+
+func TestCheckLanguage(t *testing.T) {
+	withLanguage := func(resource, property map[string]schema.RawMessage) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.Language = resource
+		r.InputProperties["name"] = schema.PropertySpec{
+			TypeSpec: schema.TypeSpec{Type: "string"},
+			Language: property,
+		}
+		return simpleResourceSchema(r)
+	}
+	old := withLanguage(
+		map[string]schema.RawMessage{"csharp": schema.RawMessage(`{"name": "Res"}`)},
+		map[string]schema.RawMessage{
+			"go":     schema.RawMessage(`{"name": "Name"}`),
+			"python": schema.RawMessage(`{"mapCase": false, "name": "name_"}`),
+		})
+	newSchema := withLanguage(nil,
+		map[string]schema.RawMessage{
+			"go":     schema.RawMessage(`{"name": "ResName"}`),
+			"python": schema.RawMessage(`{"name":"name_","mapCase":false}`),
+			"nodejs": schema.RawMessage(`{"name": "resName"}`),
+		})
+
+	changes := breakingChanges(old, newSchema, compareOptions{checkLanguage: true})
+	out := new(bytes.Buffer)
+	changes.DisplayWith(out, -1, diagtree.PlainDisplayOptions)
+	assert.Equal(t, `
+Resources
+* "my-pkg:index:MyResource":
+  * inputs: "name": language:
+    * "go" override changed
+    * "nodejs" override added
+  * language: "csharp" override removed
+`, out.String())
+
+	changes = breakingChanges(old, newSchema, compareOptions{})
+	assert.Equal(t, 0, changes.Display(new(bytes.Buffer), -1))
+}
+
+func TestCheckCycles(t *testing.T) {
+	object := func(refs ...string) schema.ComplexTypeSpec {
+		props := map[string]schema.PropertySpec{}
+		for _, ref := range refs {
+			props[ref] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Ref: "#/types/my-pkg:index:" + ref}}
+		}
+		return schema.ComplexTypeSpec{ObjectTypeSpec: schema.ObjectTypeSpec{Type: "object", Properties: props}}
+	}
+	withTypes := func(types map[string]schema.ComplexTypeSpec) schema.PackageSpec {
+		sch := simpleResourceSchema(simpleResource(nil, nil))
+		sch.Types = types
+		return sch
+	}
+	oldSchema := withTypes(map[string]schema.ComplexTypeSpec{
+		"my-pkg:index:A":    object("B"),
+		"my-pkg:index:B":    object("A"),
+		"my-pkg:index:C":    object(),
+		"my-pkg:index:Self": object(),
+	})
+	newSchema := withTypes(map[string]schema.ComplexTypeSpec{
+		"my-pkg:index:A":    object("B"),
+		"my-pkg:index:B":    object("A", "C"),
+		"my-pkg:index:C":    object("B"),
+		"my-pkg:index:Self": object("Self"),
+	})
+
+	changes := breakingChanges(oldSchema, newSchema, compareOptions{checkCycles: true})
+	out := new(bytes.Buffer)
+	changes.DisplayWith(out, -1, diagtree.PlainDisplayOptions)
+	assert.Equal(t, `
+Types
+* "my-pkg:index:A": cycle now references itself through "my-pkg:index:B", "my-pkg:index:C"
+* "my-pkg:index:Self": cycle now references itself
+`, out.String())
+
+	changes = breakingChanges(oldSchema, oldSchema, compareOptions{checkCycles: true})
+	assert.Equal(t, 0, changes.Display(new(bytes.Buffer), -1))
+}
+
+func TestEscapedTypeRef(t *testing.T) {
+	withConfig := func(ref string) schema.PackageSpec {
+		r := simpleResource(nil, nil)
+		r.InputProperties["config"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Ref: ref}}
+		sch := simpleResourceSchema(r)
+		sch.Types = map[string]schema.ComplexTypeSpec{"my-pkg:mod/sub:Config": {ObjectTypeSpec: schema.ObjectTypeSpec{
+			Type:       "object",
+			Properties: map[string]schema.PropertySpec{"a": {TypeSpec: schema.TypeSpec{Type: "string"}}},
+		}}}
+		return sch
+	}
+
+	// Refs with escaped slashes resolve to the same type as unescaped ones, so --deep finds no
+	// difference between them.
+	changes := breakingChanges(withConfig("#/types/my-pkg:mod%2Fsub:Config"),
+		withConfig("#/types/my-pkg:mod/sub:Config"), compareOptions{deep: true})
+	assert.Equal(t, []jsonDiagnostic{}, jsonDiagnostics(changes))
+}
+
+func TestCompareAcrossRepositories(t *testing.T) {
+	defer gock.Off()
+
+	old := simpleResource(nil, nil)
+	old.InputProperties["removed"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	gock.New("https://api.github.com").
+		Get("/repos/pulumi/pulumi-my-pkg/contents/provider/cmd/pulumi-resource-my-pkg/schema.json").
+		MatchParam("ref", "v1.0.0").
+		Reply(200).
+		JSON(simpleResourceSchema(old))
+	gock.New("https://ghe.mycorp.com").
+		Get("/api/v3/repos/myfork/pulumi-my-pkg/contents/provider/cmd/pulumi-resource-my-pkg/schema.json").
+		MatchParam("ref", "my-branch").
+		Reply(200).
+		JSON(simpleResourceSchema(simpleResource(nil, nil)))
+
+	// Each side is downloaded from its own repository, on its own host.
+	outPath := filepath.Join(t.TempDir(), "report.txt")
+	command := compareCmd()
+	command.SetArgs([]string{"-p", "my-pkg", "--format", "plain", "--out", outPath,
+		"--old-source", "github://api.github.com/pulumi", "--old-commit", "v1.0.0",
+		"--new-source", "github://ghe.mycorp.com/myfork/pulumi-my-pkg", "--new-commit", "my-branch"})
+	assert.NoError(t, command.Execute())
+	assert.True(t, gock.IsDone())
+
+	report, err := os.ReadFile(outPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(report), `* "my-pkg:index:MyResource": inputs: "removed" missing`)
+}
+
+func TestCompareCommentFailure(t *testing.T) {
+	defer gock.Off()
+	t.Setenv("GITHUB_TOKEN", "github-token")
+
+	old := simpleResource(nil, nil)
+	old.InputProperties["removed"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	for commit, sch := range map[string]schema.PackageSpec{
+		"v1.0.0":    simpleResourceSchema(old),
+		"my-branch": simpleResourceSchema(simpleResource(nil, nil)),
+	} {
+		gock.New("https://api.github.com").
+			Get("/repos/pulumi/pulumi-my-pkg/contents/provider/cmd/pulumi-resource-my-pkg/schema.json").
+			MatchParam("ref", commit).
+			Reply(200).
+			JSON(sch)
+	}
+	gock.New("https://api.github.com").
+		Get("/repos/pulumi/pulumi-my-pkg/issues/12/comments").
+		Reply(403).
+		BodyString(`{"message": "Resource not accessible by integration"}`)
+
+	// A failed post is a failed run, even when a threshold was also exceeded.
+	command := compareCmd()
+	command.SetArgs([]string{"-p", "my-pkg", "-o", "v1.0.0", "-n", "my-branch",
+		"--out", filepath.Join(t.TempDir(), "report.txt"),
+		"--fail-on-category", "missing-input", "--comment", "--pr", "12", "--repo", "pulumi/pulumi-my-pkg"})
+	command.SilenceErrors, command.SilenceUsage = true, true
+	err := command.Execute()
+	assert.ErrorContains(t, err, "found breaking changes in failing categories: MISSING_INPUT (1)")
+	assert.ErrorContains(t, err, "commenting on pull request pulumi/pulumi-my-pkg#12: 403 HTTP error")
+	assert.Equal(t, exitError, exitCode(err))
+	assert.True(t, gock.IsDone())
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// applyConfigFile sets the flags named by the keys of the YAML file at path, e.g.
+//
+//	provider: aws
+//	fail-on-category: [missing-resource, missing-function]
+//
+// Flags set on the command line take precedence over the file.
+func applyConfigFile(flags *pflag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	var config map[string]any
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	for name, value := range config {
+		flag := flags.Lookup(name)
+		if flag == nil || name == "config" {
+			return fmt.Errorf("config file %s: unknown flag %q", path, name)
+		}
+		if flag.Changed {
+			continue
+		}
+		s := fmt.Sprint(value)
+		if list, ok := value.([]any); ok {
+			items := make([]string, len(list))
+			for i, v := range list {
+				items[i] = fmt.Sprint(v)
+			}
+			s = strings.Join(items, ",")
+		}
+		if err := flag.Value.Set(s); err != nil {
+			return fmt.Errorf("config file %s: invalid value for %q: %w", path, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema-tools.yaml")
+	err := os.WriteFile(path, []byte(`provider: aws
+max-changes: -1
+summary: true
+fail-on-category: [missing-resource, missing-function]
+format: json
+`), 0o600)
+	assert.NoError(t, err)
+
+	var provider, format string
+	var maxChanges int
+	var summary bool
+	var categories []string
+	flags := pflag.NewFlagSet("compare", pflag.ContinueOnError)
+	flags.StringVar(&provider, "provider", "", "")
+	flags.StringVar(&format, "format", "text", "")
+	flags.IntVar(&maxChanges, "max-changes", 500, "")
+	flags.BoolVar(&summary, "summary", false, "")
+	flags.StringSliceVar(&categories, "fail-on-category", nil, "")
+
+	// Flags on the command line win over the file.
+	assert.NoError(t, flags.Parse([]string{"--format", "plain"}))
+	assert.NoError(t, applyConfigFile(flags, path))
+	assert.Equal(t, "aws", provider)
+	assert.Equal(t, "plain", format)
+	assert.Equal(t, -1, maxChanges)
+	assert.True(t, summary)
+	assert.Equal(t, []string{"missing-resource", "missing-function"}, categories)
+
+	err = os.WriteFile(path, []byte("providr: aws\n"), 0o600)
+	assert.NoError(t, err)
+	assert.EqualError(t, applyConfigFile(flags, path), `config file `+path+`: unknown flag "providr"`)
+}
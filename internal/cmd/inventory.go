@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen"
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/spf13/cobra"
+)
+
+func inventoryCmd() *cobra.Command {
+	var provider, repository, oldCommit, newCommit, format string
+
+	command := &cobra.Command{
+		Use:   "inventory",
+		Short: "List the resources, functions and types added, removed or kept between two versions of a Pulumi schema",
+		RunE: func(command *cobra.Command, args []string) error {
+			if err := validateFormat(format, formatText, formatJSON); err != nil {
+				return err
+			}
+			old := schemaSource{repository: repository, commit: oldCommit}
+			new := schemaSource{repository: repository, commit: newCommit}
+			schOld, schNew, err := loadSchemas(context.Background(), provider, old, new)
+			if err != nil {
+				return err
+			}
+			inv := takeInventory(schOld, schNew)
+			if format == formatJSON {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(inv)
+			}
+			renderInventory(os.Stdout, inv)
+			return nil
+		},
+	}
+
+	command.Flags().StringVarP(&provider, "provider", "p", "", "the provider whose schema we are comparing")
+	_ = command.MarkFlagRequired("provider")
+
+	command.Flags().StringVarP(&repository, "repository", "r",
+		"github://api.github.com/pulumi", "the Git repository to download the schema file from")
+
+	command.Flags().StringVarP(&oldCommit, "old-commit", "o", "master",
+		"the old commit to compare with")
+
+	command.Flags().StringVarP(&newCommit, "new-commit", "n", "",
+		"the new commit to compare against the old commit")
+	_ = command.MarkFlagRequired("new-commit")
+
+	command.Flags().StringVar(&format, "format", formatText, "the output format: text or json")
+
+	return command
+}
+
+// inventory lists which tokens of each section of a schema were added, removed or kept. Unlike a
+// comparison, it never looks inside resources, functions or types.
+type inventory struct {
+	Resources tokenInventory `json:"resources"`
+	Functions tokenInventory `json:"functions"`
+	Types     tokenInventory `json:"types"`
+}
+
+type tokenInventory struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Common  []string `json:"common"`
+}
+
+func takeInventory(oldSchema, newSchema schema.PackageSpec) inventory {
+	return inventory{
+		Resources: inventoryOf(oldSchema.Resources, newSchema.Resources),
+		Functions: inventoryOf(oldSchema.Functions, newSchema.Functions),
+		Types:     inventoryOf(oldSchema.Types, newSchema.Types),
+	}
+}
+
+// inventoryOf sorts the tokens of old and new into added, removed and common ones, each sorted.
+func inventoryOf[T any](old, new map[string]T) tokenInventory {
+	inv := tokenInventory{Added: []string{}, Removed: []string{}, Common: []string{}}
+	for _, token := range codegen.SortedKeys(old) {
+		if _, ok := new[token]; ok {
+			inv.Common = append(inv.Common, token)
+		} else {
+			inv.Removed = append(inv.Removed, token)
+		}
+	}
+	for _, token := range codegen.SortedKeys(new) {
+		if _, ok := old[token]; !ok {
+			inv.Added = append(inv.Added, token)
+		}
+	}
+	return inv
+}
+
+// renderInventory writes the counts of each section, followed by its added and removed tokens.
+// Common tokens are only counted, since they are most of the schema.
+func renderInventory(out io.Writer, inv inventory) {
+	for i, section := range []struct {
+		title string
+		inv   tokenInventory
+	}{
+		{"Resources", inv.Resources},
+		{"Functions", inv.Functions},
+		{"Types", inv.Types},
+	} {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "### %s: %d added, %d removed, %d common\n", section.title,
+			len(section.inv.Added), len(section.inv.Removed), len(section.inv.Common))
+		for _, token := range section.inv.Added {
+			fmt.Fprintf(out, "- added `%s`\n", token)
+		}
+		for _, token := range section.inv.Removed {
+			fmt.Fprintf(out, "- removed `%s`\n", token)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventory(t *testing.T) {
+	oldSchema := simpleResourceSchema(simpleResource(nil, nil))
+	oldSchema.Resources["my-pkg:index:Removed"] = simpleResource(nil, nil)
+	oldSchema.Types = map[string]schema.ComplexTypeSpec{"my-pkg:index:Kept": {}}
+
+	newSchema := simpleResourceSchema(simpleResource(nil, []string{"value"}))
+	newSchema.Resources["my-pkg:index:Added"] = simpleResource(nil, nil)
+	newSchema.Functions = map[string]schema.FunctionSpec{"my-pkg:index:getAdded": {}}
+	newSchema.Types = oldSchema.Types
+
+	inv := takeInventory(oldSchema, newSchema)
+	assert.Equal(t, inventory{
+		Resources: tokenInventory{
+			Added:   []string{"my-pkg:index:Added"},
+			Removed: []string{"my-pkg:index:Removed"},
+			Common:  []string{"my-pkg:index:MyResource"},
+		},
+		Functions: tokenInventory{
+			Added:   []string{"my-pkg:index:getAdded"},
+			Removed: []string{},
+			Common:  []string{},
+		},
+		Types: tokenInventory{
+			Added:   []string{},
+			Removed: []string{},
+			Common:  []string{"my-pkg:index:Kept"},
+		},
+	}, inv)
+
+	out := new(bytes.Buffer)
+	renderInventory(out, inv)
+	assert.Equal(t, "### Resources: 1 added, 1 removed, 1 common\n"+
+		"- added `my-pkg:index:Added`\n"+
+		"- removed `my-pkg:index:Removed`\n"+
+		"\n### Functions: 1 added, 0 removed, 0 common\n"+
+		"- added `my-pkg:index:getAdded`\n"+
+		"\n### Types: 0 added, 0 removed, 1 common\n",
+		out.String())
+}
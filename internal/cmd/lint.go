@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/spf13/cobra"
+
+	"github.com/pulumi/schema-tools/internal/pkg"
+)
+
+func lintCmd() *cobra.Command {
+	var provider, repository, commit string
+	var minCoverage float64
+	var worst int
+
+	command := &cobra.Command{
+		Use:   "lint",
+		Short: "Check that a Pulumi schema meets documentation quality thresholds",
+		RunE: func(command *cobra.Command, args []string) error {
+			if minCoverage < 0 || minCoverage > 1 {
+				return fmt.Errorf("--min-description-coverage must be between 0 and 1, was %v", minCoverage)
+			}
+			sch, err := loadSchema(context.Background(), repository, provider, commit)
+			if err != nil {
+				return err
+			}
+			return lint(os.Stdout, sch, minCoverage, worst)
+		},
+	}
+
+	command.Flags().StringVarP(&provider, "provider", "p", "",
+		"the provider whose schema we should lint")
+	_ = command.MarkFlagRequired("provider")
+
+	command.Flags().StringVarP(&repository, "repository", "r", "github://api.github.com/pulumi",
+		"the Git repository to download the schema file from")
+
+	command.Flags().StringVarP(&commit, "commit", "c", "master",
+		"the commit to lint, accepts the same values as compare's --new-commit")
+
+	command.Flags().Float64Var(&minCoverage, "min-description-coverage", 0,
+		"fail when less than this share of properties, between 0 and 1, have a description")
+
+	command.Flags().IntVar(&worst, "worst", 10,
+		"the number of resources and functions missing the most descriptions to list")
+
+	return command
+}
+
+// lint reports the description coverage of sch and its worst offenders, and fails if the
+// coverage is below minCoverage.
+func lint(out io.Writer, sch schema.PackageSpec, minCoverage float64, worst int) error {
+	coverage := pkg.DescriptionCoverage(pkg.CountStats(sch))
+	fmt.Fprintf(out, "Description coverage: %.1f%% (minimum %.1f%%)\n", coverage*100, minCoverage*100)
+
+	missing := pkg.MissingDescriptionsByToken(sch)
+	tokens := make([]string, 0, len(missing))
+	for token := range missing {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if missing[tokens[i]] != missing[tokens[j]] {
+			return missing[tokens[i]] > missing[tokens[j]]
+		}
+		return tokens[i] < tokens[j]
+	})
+	if len(tokens) > worst {
+		tokens = tokens[:worst]
+	}
+
+	if len(tokens) > 0 {
+		fmt.Fprintf(out, "\nMost properties missing descriptions:\n\n")
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TOKEN\tMISSING DESC")
+		for _, token := range tokens {
+			fmt.Fprintf(w, "%s\t%d\n", token, missing[token])
+		}
+		_ = w.Flush()
+	}
+
+	if coverage < minCoverage {
+		return thresholdError{fmt.Errorf("description coverage %.1f%% is below the minimum of %.1f%%",
+			coverage*100, minCoverage*100)}
+	}
+	return nil
+}
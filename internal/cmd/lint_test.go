@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	sch := schema.PackageSpec{
+		Resources: map[string]schema.ResourceSpec{
+			"aws:ec2/instance:Instance": {
+				InputProperties: map[string]schema.PropertySpec{
+					"ami":  {},
+					"type": {},
+				},
+			},
+			"aws:ec2/vpc:Vpc": {
+				InputProperties: map[string]schema.PropertySpec{
+					"cidr": {},
+				},
+			},
+			"aws:s3/bucket:Bucket": {
+				InputProperties: map[string]schema.PropertySpec{
+					"acl":    {Description: "The ACL."},
+					"bucket": {Description: "The name."},
+				},
+			},
+		},
+	}
+
+	out := new(bytes.Buffer)
+	err := lint(out, sch, 0.4, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, `Description coverage: 40.0% (minimum 40.0%)
+
+Most properties missing descriptions:
+
+TOKEN                      MISSING DESC
+aws:ec2/instance:Instance  2
+`, out.String())
+
+	err = lint(new(bytes.Buffer), sch, 0.9, 10)
+	assert.EqualError(t, err, "description coverage 40.0% is below the minimum of 90.0%")
+}
@@ -1,28 +1,74 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"github.com/spf13/cobra"
 	"os"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/logging"
+	"github.com/spf13/cobra"
+
+	"github.com/pulumi/schema-tools/internal/pkg"
 )
 
 func rootCmd() *cobra.Command {
+	var verbose bool
 	command := &cobra.Command{
 		Use:   "schema-tools",
 		Short: "schema-tools is a CLI utility to analyze Pulumi schemas",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			if verbose {
+				logging.InitLogging(true, 1, false)
+			}
+		},
 	}
 
+	command.PersistentFlags().DurationVar(&pkg.DownloadTimeout, "timeout", pkg.DownloadTimeout,
+		"the maximum time to spend downloading each schema")
+
+	command.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false,
+		"log the progress of long running commands to stderr")
+
 	command.AddCommand(compareCmd())
 	command.AddCommand(statsCmd())
 	command.AddCommand(versionCmd())
 	command.AddCommand(squeezeCmd())
+	command.AddCommand(validateCmd())
+	command.AddCommand(lintCmd())
+	command.AddCommand(changelogCmd())
+	command.AddCommand(inventoryCmd())
 
 	return command
 }
 
+// Exit codes, so that CI can tell a broken schema from a broken run.
+const (
+	// exitError is returned when the command itself failed, e.g. a schema couldn't be downloaded.
+	exitError = 1
+	// exitThreshold is returned when the command ran, but what it found exceeds a threshold set
+	// by the user, such as --fail-on-category.
+	exitThreshold = 3
+)
+
+// thresholdError reports findings that exceed a threshold set by the user, as opposed to a
+// failure to run the command.
+type thresholdError struct{ err error }
+
+func (e thresholdError) Error() string { return e.err.Error() }
+
+func (e thresholdError) Unwrap() error { return e.err }
+
+func exitCode(err error) int {
+	var threshold thresholdError
+	if errors.As(err, &threshold) {
+		return exitThreshold
+	}
+	return exitError
+}
+
 func Execute() {
 	if err := rootCmd().Execute(); err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
@@ -1,24 +1,32 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	mapset "github.com/deckarep/golang-set/v2"
 
 	"github.com/pulumi/pulumi/pkg/v3/codegen"
 	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/logging"
 	"github.com/spf13/cobra"
 
 	"github.com/pulumi/schema-tools/internal/pkg"
 )
 
 func squeezeCmd() *cobra.Command {
-	var oldRes, newRes, res, source, out string
+	var oldRes, newRes, res, source, provider, commit, out, report, format, since, until string
+	var validate bool
 	command := &cobra.Command{
 		Use:   "squeeze",
 		Short: "Utilities to compare Azure Native versions on backward compatibility",
@@ -26,41 +34,74 @@ func squeezeCmd() *cobra.Command {
 			if source == "" {
 				return fmt.Errorf("source path is required")
 			}
+			if err := validateFormat(format, formatText, formatJSON); err != nil {
+				return err
+			}
+			groupMode := res != "" && (oldRes == "" || newRes == "")
+			if (since != "" || until != "") && !groupMode {
+				return fmt.Errorf(`"since" and "until" can only be used with "resource"`)
+			}
+			sch, err := readSchema(cmd.InOrStdin(), source, provider, commit, validate)
+			if err != nil {
+				return err
+			}
 			if oldRes != "" && newRes != "" {
-				return compareTwo(source, oldRes, newRes)
+				return compareTwo(cmd.OutOrStdout(), sch, oldRes, newRes, format)
 			}
 			if res != "" {
-				return compareGroup(source, res)
+				window, err := parseDateWindow(since, until)
+				if err != nil {
+					return err
+				}
+				return compareGroup(cmd.OutOrStdout(), cmd.ErrOrStderr(), sch, res, format, window)
 			}
-			return compareAll(source, out)
+			return compareAll(cmd.OutOrStdout(), sch, out, report, format)
 		},
 	}
 	command.Flags().StringVarP(&oldRes, "old", "o", "", "old resource name")
 	command.Flags().StringVarP(&newRes, "new", "n", "", "new resource name")
-	command.Flags().StringVarP(&source, "source", "s", "", "source schema path")
+	command.Flags().StringVarP(&source, "source", "s", "",
+		"source schema path, - to read it from stdin, or a repository URL as accepted by compare's --repository")
+	command.Flags().StringVarP(&provider, "provider", "p", "azure-native",
+		"the provider whose schema to download (when the source is a repository URL)")
+	command.Flags().StringVarP(&commit, "commit", "c", "master",
+		"the commit to download the schema from (when the source is a repository URL)")
 	command.Flags().StringVarP(&res, "resource", "r", "", "resource (default) name")
 	command.Flags().StringVar(&out, "out", "", "replacements output path (when comparing all resources)")
+	command.Flags().StringVar(&report, "report", "",
+		"output path for a report explaining each replacement (when comparing all resources)")
+	command.Flags().StringVar(&format, "format", formatText,
+		fmt.Sprintf("the output format, one of: %s, %s", formatText, formatJSON))
+	command.Flags().StringVar(&since, "since", "",
+		"only consider API versions from this date on, as YYYY-MM-DD (when comparing a resource group)")
+	command.Flags().StringVar(&until, "until", "",
+		"only consider API versions up to this date, as YYYY-MM-DD (when comparing a resource group)")
+	command.Flags().BoolVar(&validate, "validate", false,
+		"check that the source schema is well formed before comparing its resources")
 
 	return command
 }
 
-func compareTwo(path, oldName, newName string) error {
-	sch, err := readSchema(path)
+func compareTwo(out io.Writer, sch *schema.PackageSpec, oldName, newName, format string) error {
+	violations, _, err := compareResources(sch, oldName, newName)
 	if err != nil {
 		return err
 	}
-
-	violations, err := compareResources(sch, oldName, newName)
-	if err != nil {
-		return err
+	if format == formatJSON {
+		return printJSON(out, struct {
+			Old        string   `json:"old"`
+			New        string   `json:"new"`
+			Violations []string `json:"violations"`
+		}{oldName, newName, nonNil(violations)})
 	}
+
 	switch len(violations) {
 	case 0:
-		fmt.Println("Looking good! No breaking changes found.")
+		fmt.Fprintln(out, "Looking good! No breaking changes found.")
 	case 1:
-		fmt.Println("Found 1 breaking change:")
+		fmt.Fprintln(out, "Found 1 breaking change:")
 	default:
-		fmt.Printf("Found %d breaking changes:\n", len(violations))
+		fmt.Fprintf(out, "Found %d breaking changes:\n", len(violations))
 	}
 
 	var violationDetails []string
@@ -71,47 +112,51 @@ func compareTwo(path, oldName, newName string) error {
 	}
 
 	for _, v := range violationDetails {
-		fmt.Println(v)
+		fmt.Fprintln(out, v)
 	}
 	return nil
 }
 
-func compareGroup(path, groupName string) error {
-	sch, err := readSchema(path)
-	if err != nil {
-		return err
-	}
-
+func compareGroup(out, errOut io.Writer, sch *schema.PackageSpec, groupName, format string, window dateWindow) error {
 	resVersions := mapset.NewSet[string]()
 	for name := range sch.Resources {
 		if !pkg.IsVersionedName(name) {
 			continue
 		}
-		if groupName == pkg.VersionlessName(name) {
-			resVersions.Add(name)
+		if groupName != pkg.VersionlessName(name) {
+			continue
+		}
+		if ok, err := window.contains(name); err != nil {
+			fmt.Fprintf(errOut, "skipping %s: %v\n", name, err)
+			continue
+		} else if !ok {
+			continue
 		}
+		resVersions.Add(name)
 	}
 
-	uniqueVersions := calculateUniqueVersions(sch, resVersions)
+	uniqueVersions, _ := calculateUniqueVersions(sch, resVersions)
 
-	fmt.Println("All versions:")
+	if format == formatJSON {
+		return printJSON(out, struct {
+			AllVersions                  []string `json:"all_versions"`
+			NotForwardCompatibleVersions []string `json:"not_forward_compatible_versions"`
+		}{mapset.Sorted(resVersions), mapset.Sorted(uniqueVersions)})
+	}
+
+	fmt.Fprintln(out, "All versions:")
 	for _, name := range mapset.Sorted(resVersions) {
-		fmt.Printf("%s\n", name)
+		fmt.Fprintf(out, "%s\n", name)
 	}
-	fmt.Println("Not forward-compatible versions:")
+	fmt.Fprintln(out, "Not forward-compatible versions:")
 	for _, name := range mapset.Sorted(uniqueVersions) {
-		fmt.Printf("%s\n", name)
+		fmt.Fprintf(out, "%s\n", name)
 	}
 
 	return nil
 }
 
-func compareAll(path, out string) error {
-	sch, err := readSchema(path)
-	if err != nil {
-		return err
-	}
-
+func compareAll(out io.Writer, sch *schema.PackageSpec, outPath, report, format string) error {
 	resourceMap := map[string]mapset.Set[string]{}
 	for name := range sch.Resources {
 		if !pkg.IsVersionedName(name) {
@@ -127,13 +172,25 @@ func compareAll(path, out string) error {
 	}
 
 	sortedKeys := codegen.SortedKeys(resourceMap)
+	groups := make([]mapset.Set[string], len(sortedKeys))
+	for i, name := range sortedKeys {
+		groups[i] = resourceMap[name]
+	}
+	logging.V(1).Infof("comparing the versions of %d resources", len(groups))
+	results := calculateAllUniqueVersions(sch, groups)
+	logging.V(1).Infof("done")
+
 	replacements := map[string]string{}
-	for _, name := range sortedKeys {
-		group := resourceMap[name]
-		unique := calculateUniqueVersions(sch, group)
+	reductions := map[string]versionReduction{}
+	allReduced := []string{}
+	for i, group := range groups {
+		unique, groupReductions := results[i].unique, results[i].reductions
 		reduced := group.Difference(unique)
-		for r := range reduced.Iter() {
-			fmt.Println(r)
+		allReduced = append(allReduced, mapset.Sorted(reduced)...)
+		if format == formatText {
+			for r := range reduced.Iter() {
+				fmt.Fprintln(out, r)
+			}
 		}
 		for k := range reduced.Iter() {
 			for _, a := range mapset.Sorted(unique) {
@@ -143,24 +200,65 @@ func compareAll(path, out string) error {
 				}
 			}
 		}
+		for k, r := range groupReductions {
+			r.ReplacedBy = replacements[k]
+			reductions[k] = r
+		}
 	}
 
-	if out != "" {
-		return writeJSONToFile(out, replacements)
+	if outPath != "" {
+		if err := writeJSONToFile(outPath, replacements); err != nil {
+			return err
+		}
+	}
+	if report != "" {
+		if err := writeJSONToFile(report, reductions); err != nil {
+			return err
+		}
+	}
+	if format == formatJSON {
+		return printJSON(out, struct {
+			Reduced      []string          `json:"reduced_versions"`
+			Replacements map[string]string `json:"replacements"`
+		}{allReduced, replacements})
 	}
 	return nil
 }
 
-func compareResources(sch *schema.PackageSpec, oldName string, newName string) ([]string, error) {
+// versionReduction explains why a version of a resource was found to be redundant.
+type versionReduction struct {
+	// CompatibleWith is the newer version of the resource that was found to be backward
+	// compatible with the reduced version.
+	CompatibleWith string `json:"compatible_with"`
+
+	// ReplacedBy is the version the reduced version is replaced with in the replacements
+	// output, if any.
+	ReplacedBy string `json:"replaced_by,omitempty"`
+
+	// MatchedTypes lists the versioned types of the reduced resource that were compared
+	// against their counterparts in CompatibleWith and found identical.
+	MatchedTypes []typeMatch `json:"matched_types"`
+}
+
+// typeMatch is a pair of versioned types that were compared with each other.
+type typeMatch struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// compareResources returns the backward-incompatible changes between two versions of a
+// resource, along with the pairs of versioned types that were compared along the way.
+func compareResources(sch *schema.PackageSpec, oldName string, newName string) ([]string, []typeMatch, error) {
 	var violations []string
 	oldRes, ok := sch.Resources[oldName]
 	if !ok {
-		return nil, fmt.Errorf("resource %q missing", oldName)
+		return nil, nil, fmt.Errorf("resource %q missing", oldName)
 	}
 	newRes, ok := sch.Resources[newName]
 	if !ok {
-		return nil, fmt.Errorf("resource %q missing", newName)
+		return nil, nil, fmt.Errorf("resource %q missing", newName)
 	}
+	matched := mapset.NewThreadUnsafeSet[typeMatch]()
 
 	for propName, prop := range oldRes.InputProperties {
 		newProp, ok := newRes.InputProperties[propName]
@@ -169,7 +267,7 @@ func compareResources(sch *schema.PackageSpec, oldName string, newName string) (
 			continue
 		}
 
-		vs := validateTypesDeep(sch, &prop.TypeSpec, &newProp.TypeSpec, fmt.Sprintf("Resource %q input %q", newName, propName), true)
+		vs := validateTypesDeep(sch, &prop.TypeSpec, &newProp.TypeSpec, fmt.Sprintf("Resource %q input %q", newName, propName), true, matched)
 		violations = append(violations, vs...)
 	}
 
@@ -180,7 +278,7 @@ func compareResources(sch *schema.PackageSpec, oldName string, newName string) (
 			continue
 		}
 
-		vs := validateTypesDeep(sch, &prop.TypeSpec, &newProp.TypeSpec, fmt.Sprintf("Resource %q output %q", newName, propName), false)
+		vs := validateTypesDeep(sch, &prop.TypeSpec, &newProp.TypeSpec, fmt.Sprintf("Resource %q output %q", newName, propName), false, matched)
 		violations = append(violations, vs...)
 	}
 
@@ -198,11 +296,62 @@ func compareResources(sch *schema.PackageSpec, oldName string, newName string) (
 		}
 	}
 
-	return violations, nil
+	matches := matched.ToSlice()
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Old != matches[j].Old {
+			return matches[i].Old < matches[j].Old
+		}
+		return matches[i].New < matches[j].New
+	})
+	return violations, matches, nil
+}
+
+// groupVersions is the result of calculateUniqueVersions for a single resource group.
+type groupVersions struct {
+	unique     mapset.Set[string]
+	reductions map[string]versionReduction
 }
 
-func calculateUniqueVersions(sch *schema.PackageSpec, resVersions mapset.Set[string]) mapset.Set[string] {
+// calculateAllUniqueVersions runs calculateUniqueVersions for each group, spreading the groups
+// over GOMAXPROCS workers. The results are in the same order as groups.
+//
+// This is safe because comparing resources only reads from sch.
+func calculateAllUniqueVersions(sch *schema.PackageSpec, groups []mapset.Set[string]) []groupVersions {
+	results := make([]groupVersions, len(groups))
+	jobs := make(chan int)
+	var done atomic.Int64
+
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				unique, reductions := calculateUniqueVersions(sch, groups[i])
+				results[i] = groupVersions{unique, reductions}
+				if n := done.Add(1); n%100 == 0 {
+					logging.V(1).Infof("compared %d of %d resource groups", n, len(groups))
+				}
+			}
+		}()
+	}
+
+	for i := range groups {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// calculateUniqueVersions returns the versions in resVersions that are not backward compatible
+// with any newer version, and explains why each of the other versions is redundant.
+func calculateUniqueVersions(
+	sch *schema.PackageSpec, resVersions mapset.Set[string],
+) (mapset.Set[string], map[string]versionReduction) {
 	uniqueVersions := mapset.NewSet[string]()
+	reductions := map[string]versionReduction{}
 
 	sortedVersions := mapset.Sorted(resVersions)
 	sortApiVersions(sortedVersions)
@@ -213,22 +362,83 @@ outer:
 			if oldName >= newName {
 				continue
 			}
-			violations, err := compareResources(sch, oldName, newName)
+			violations, matched, err := compareResources(sch, oldName, newName)
 			if err == nil && len(violations) == 0 {
+				reductions[oldName] = versionReduction{
+					CompatibleWith: newName,
+					MatchedTypes:   nonNil(matched),
+				}
 				continue outer
 			}
 		}
 		uniqueVersions.Add(oldName)
 	}
-	return uniqueVersions
+	return uniqueVersions, reductions
+}
+
+// dateWindow restricts the API versions considered by squeeze. Zero bounds are open.
+type dateWindow struct {
+	since, until time.Time
 }
 
+func parseDateWindow(since, until string) (dateWindow, error) {
+	var window dateWindow
+	var err error
+	if since != "" {
+		if window.since, err = time.Parse("2006-01-02", since); err != nil {
+			return window, fmt.Errorf("invalid --since date: %w", err)
+		}
+	}
+	if until != "" {
+		if window.until, err = time.Parse("2006-01-02", until); err != nil {
+			return window, fmt.Errorf("invalid --until date: %w", err)
+		}
+	}
+	return window, nil
+}
+
+// contains reports whether the API version of the resource token falls within the window. It
+// fails when the window is bounded and the date of the version can't be parsed.
+func (w dateWindow) contains(token string) (bool, error) {
+	if w.since.IsZero() && w.until.IsZero() {
+		return true, nil
+	}
+	date, err := apiVersionToDate(tokenAPIVersion(token))
+	if err != nil {
+		return false, err
+	}
+	if !w.since.IsZero() && date.Before(w.since) {
+		return false, nil
+	}
+	if !w.until.IsZero() && date.After(w.until) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// tokenAPIVersion returns the API version in the module of a token, e.g. "v20230501" for
+// "azure-native:app/v20230501:ContainerApp".
+func tokenAPIVersion(token string) string {
+	parts := strings.Split(token, ":")
+	if len(parts) != 3 {
+		return ""
+	}
+	_, version, _ := strings.Cut(parts[1], "/")
+	return version
+}
+
+// apiVersionToDate parses the date of an API version in either the compact (v20230101) or the
+// dashed (v2023-01-01) form, ignoring suffixes like "preview" or "-privatepreview".
 func apiVersionToDate(apiVersion string) (time.Time, error) {
-	if len(apiVersion) < 9 {
+	v := strings.TrimPrefix(apiVersion, "v")
+	switch {
+	case len(v) >= 10 && v[4] == '-' && v[7] == '-':
+		return time.Parse("2006-01-02", v[:10])
+	case len(v) >= 8:
+		return time.Parse("20060102", v[:8])
+	default:
 		return time.Time{}, fmt.Errorf("invalid API version %q", apiVersion)
 	}
-	// The API version is in the format YYYY-MM-DD - ignore suffixes like "-preview".
-	return time.Parse("20060102", apiVersion[1:9])
 }
 
 func compareApiVersions(a, b string) int {
@@ -281,7 +491,10 @@ func sortApiVersions(versions []string) {
 	})
 }
 
-func validateTypesDeep(sch *schema.PackageSpec, old *schema.TypeSpec, new *schema.TypeSpec, prefix string, input bool) (violations []string) {
+func validateTypesDeep(
+	sch *schema.PackageSpec, old *schema.TypeSpec, new *schema.TypeSpec, prefix string, input bool,
+	matched mapset.Set[typeMatch],
+) (violations []string) {
 	switch {
 	case old == nil && new == nil:
 		return
@@ -306,6 +519,7 @@ func validateTypesDeep(sch *schema.PackageSpec, old *schema.TypeSpec, new *schem
 			strings.HasPrefix(newType, "#/types/azure-native") &&
 			pkg.VersionlessName(oldType) == pkg.VersionlessName(newType) { // resources:MyType
 			// Both are reference types, let's do a deep comparison
+			matched.Add(typeMatch{Old: oldType, New: newType})
 			oldTypeRef := sch.Types[oldType]
 			newTypeRef := sch.Types[newType]
 			for propName, prop := range oldTypeRef.Properties {
@@ -315,7 +529,7 @@ func validateTypesDeep(sch *schema.PackageSpec, old *schema.TypeSpec, new *schem
 					continue
 				}
 
-				vs := validateTypesDeep(sch, &prop.TypeSpec, &newProp.TypeSpec, fmt.Sprintf("Type %q input %q", newType, propName), input)
+				vs := validateTypesDeep(sch, &prop.TypeSpec, &newProp.TypeSpec, fmt.Sprintf("Type %q input %q", newType, propName), input, matched)
 				violations = append(violations, vs...)
 			}
 
@@ -338,19 +552,55 @@ func validateTypesDeep(sch *schema.PackageSpec, old *schema.TypeSpec, new *schem
 			violations = append(violations, fmt.Sprintf("%s type changed from %q to %q", prefix, oldType, newType))
 		}
 	}
-	violations = append(violations, validateTypesDeep(sch, old.Items, new.Items, prefix+" items", input)...)
-	violations = append(violations, validateTypesDeep(sch, old.AdditionalProperties, new.AdditionalProperties, prefix+" additional properties", input)...)
+	violations = append(violations, validateTypesDeep(sch, old.Items, new.Items, prefix+" items", input, matched)...)
+	violations = append(violations, validateTypesDeep(sch, old.AdditionalProperties, new.AdditionalProperties, prefix+" additional properties", input, matched)...)
 	return
 }
 
-func readSchema(path string) (*schema.PackageSpec, error) {
-	sch, err := pkg.LoadLocalPackageSpec(path)
+// readSchema loads the schema at source, which is either a local path, "-" to read the schema
+// from stdin, or a repository URL to download the schema of provider at commit from.
+func readSchema(stdin io.Reader, source, provider, commit string, validate bool) (*schema.PackageSpec, error) {
+	logging.V(1).Infof("reading the schema from %s", source)
+	var sch schema.PackageSpec
+	var err error
+	switch {
+	case source == "-":
+		source = "stdin"
+		sch, err = pkg.LoadPackageSpec(stdin)
+		if err != nil {
+			err = fmt.Errorf("reading the schema from stdin: %w", err)
+		}
+	case isURL(source):
+		sch, err = pkg.DownloadSchema(context.Background(), source, provider, commit)
+	default:
+		sch, err = pkg.LoadLocalPackageSpec(source)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if validate {
+		if err := pkg.ValidatePackageSpec(sch); err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %w", source, err)
+		}
+	}
 	return &sch, nil
 }
 
+// isURL reports whether source has a URL scheme. Single letter schemes are Windows drive letters.
+func isURL(source string) bool {
+	u, err := url.Parse(source)
+	return err == nil && len(u.Scheme) > 1
+}
+
+func printJSON(out io.Writer, data interface{}) error {
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing to JSON: %w", err)
+	}
+	_, err = fmt.Fprintln(out, string(jsonData))
+	return err
+}
+
 func writeJSONToFile(filename string, data interface{}) error {
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
@@ -1,8 +1,13 @@
 package cmd
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,6 +29,80 @@ func TestApiVersionToDate(t *testing.T) {
 		actual := date.Format("2006-01-02")
 		assert.Equal(t, expected, actual)
 	})
+
+	for _, apiVersion := range []string{
+		"v2020-01-31",
+		"v2020-01-31-preview",
+		"v2020-01-31-privatepreview",
+		"v20200131privatepreview",
+	} {
+		apiVersion := apiVersion
+		t.Run(apiVersion, func(t *testing.T) {
+			date, err := apiVersionToDate(apiVersion)
+			assert.NoError(t, err)
+			assert.Equal(t, "2020-01-31", date.Format("2006-01-02"))
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := apiVersionToDate("v2020")
+		assert.Error(t, err)
+
+		_, err = apiVersionToDate("v2020-13-01")
+		assert.Error(t, err)
+	})
+}
+
+func TestDateWindow(t *testing.T) {
+	window, err := parseDateWindow("2021-01-01", "2022-12-31")
+	assert.NoError(t, err)
+
+	for token, expected := range map[string]bool{
+		"azure-native:app/v20200101:App":           false,
+		"azure-native:app/v20210101:App":           true,
+		"azure-native:app/v2022-06-01-preview:App": true,
+		"azure-native:app/v20230101:App":           false,
+	} {
+		actual, err := window.contains(token)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual, token)
+	}
+
+	_, err = window.contains("azure-native:app/vnext:App")
+	assert.Error(t, err)
+
+	open, err := parseDateWindow("", "")
+	assert.NoError(t, err)
+	ok, err := open.contains("azure-native:app/vnext:App")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = parseDateWindow("01/01/2021", "")
+	assert.ErrorContains(t, err, "invalid --since date")
+}
+
+func TestCompareGroupWindow(t *testing.T) {
+	sch, _ := versionedSchema(1, 4)
+	sch.Resources["azure-native:mod0/vnext:Res"] = schema.ResourceSpec{}
+	window, err := parseDateWindow("2001-01-01", "2002-12-31")
+	assert.NoError(t, err)
+
+	// Versions without a date can't be placed in the window, so they are skipped with a note.
+	out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+	assert.NoError(t, compareGroup(out, errOut, sch, "mod0:Res", formatText, window))
+	assert.Equal(t, "All versions:\n"+
+		"azure-native:mod0/v20010101:Res\n"+
+		"azure-native:mod0/v20020101:Res\n"+
+		"Not forward-compatible versions:\n"+
+		"azure-native:mod0/v20010101:Res\n"+
+		"azure-native:mod0/v20020101:Res\n", out.String())
+	assert.Equal(t, "skipping azure-native:mod0/vnext:Res: invalid API version \"vnext\"\n", errOut.String())
+
+	// The window only applies to a resource group.
+	command := squeezeCmd()
+	command.SetArgs([]string{"-s", "file:../pkg/schema.json", "--since", "2001-01-01"})
+	command.SilenceErrors, command.SilenceUsage = true, true
+	assert.EqualError(t, command.Execute(), `"since" and "until" can only be used with "resource"`)
 }
 
 func TestSortApiVersions(t *testing.T) {
@@ -48,6 +127,13 @@ func TestSortApiVersions(t *testing.T) {
 		assert.Equal(t, expected, versions)
 	})
 
+	t.Run("dashed versions are ordered by date", func(t *testing.T) {
+		versions := []string{"v2021-02-02", "v2020-01-01-preview", "v2020-01-01", "v2020-01-01-privatepreview"}
+		sortApiVersions(versions)
+		expected := []string{"v2020-01-01-privatepreview", "v2020-01-01-preview", "v2020-01-01", "v2021-02-02"}
+		assert.Equal(t, expected, versions)
+	})
+
 	t.Run("private comes before preview", func(t *testing.T) {
 		versions := []string{"v20200101preview", "v20200101privatepreview"}
 		sortApiVersions(versions)
@@ -55,3 +141,164 @@ func TestSortApiVersions(t *testing.T) {
 		assert.Equal(t, expected, versions)
 	})
 }
+
+func TestCalculateUniqueVersions(t *testing.T) {
+	resource := func(typeToken string, props ...string) schema.ResourceSpec {
+		inputs := map[string]schema.PropertySpec{
+			"settings": {TypeSpec: schema.TypeSpec{Ref: "#/types/" + typeToken}},
+		}
+		for _, p := range props {
+			inputs[p] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+		}
+		return schema.ResourceSpec{InputProperties: inputs}
+	}
+	settings := schema.ComplexTypeSpec{ObjectTypeSpec: schema.ObjectTypeSpec{
+		Type: "object",
+		Properties: map[string]schema.PropertySpec{
+			"name": {TypeSpec: schema.TypeSpec{Type: "string"}},
+		},
+	}}
+	sch := &schema.PackageSpec{
+		Resources: map[string]schema.ResourceSpec{
+			"azure-native:app/v20220101:App": resource("azure-native:app/v20220101:Settings", "legacy"),
+			"azure-native:app/v20230101:App": resource("azure-native:app/v20230101:Settings"),
+			"azure-native:app/v20240101:App": resource("azure-native:app/v20240101:Settings"),
+		},
+		Types: map[string]schema.ComplexTypeSpec{
+			"azure-native:app/v20220101:Settings": settings,
+			"azure-native:app/v20230101:Settings": settings,
+			"azure-native:app/v20240101:Settings": settings,
+		},
+	}
+
+	unique, reductions := calculateUniqueVersions(sch, mapset.NewSet(
+		"azure-native:app/v20220101:App",
+		"azure-native:app/v20230101:App",
+		"azure-native:app/v20240101:App",
+	))
+
+	assert.Equal(t, []string{
+		"azure-native:app/v20220101:App",
+		"azure-native:app/v20240101:App",
+	}, mapset.Sorted(unique))
+	assert.Equal(t, map[string]versionReduction{
+		"azure-native:app/v20230101:App": {
+			CompatibleWith: "azure-native:app/v20240101:App",
+			MatchedTypes: []typeMatch{{
+				Old: "#/types/azure-native:app/v20230101:Settings",
+				New: "#/types/azure-native:app/v20240101:Settings",
+			}},
+		},
+	}, reductions)
+}
+
+// versionedSchema builds a schema with the given number of resource groups, each with the given
+// number of versions. Every other version drops a property, so half of the versions are unique.
+func versionedSchema(groups, versions int) (*schema.PackageSpec, []mapset.Set[string]) {
+	sch := &schema.PackageSpec{Resources: map[string]schema.ResourceSpec{}}
+	sets := make([]mapset.Set[string], groups)
+	for g := 0; g < groups; g++ {
+		sets[g] = mapset.NewSet[string]()
+		for v := 0; v < versions; v++ {
+			name := fmt.Sprintf("azure-native:mod%d/v%d0101:Res", g, 2000+v)
+			inputs := map[string]schema.PropertySpec{}
+			for p := v / 2; p < versions; p++ {
+				inputs[fmt.Sprintf("prop%d", p)] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+			}
+			sch.Resources[name] = schema.ResourceSpec{InputProperties: inputs}
+			sets[g].Add(name)
+		}
+	}
+	return sch, sets
+}
+
+func TestCalculateAllUniqueVersions(t *testing.T) {
+	sch, groups := versionedSchema(20, 6)
+
+	results := calculateAllUniqueVersions(sch, groups)
+
+	assert.Len(t, results, len(groups))
+	assert.Equal(t, 3, results[0].unique.Cardinality())
+	for i, group := range groups {
+		unique, reductions := calculateUniqueVersions(sch, group)
+		assert.Equal(t, mapset.Sorted(unique), mapset.Sorted(results[i].unique))
+		assert.Equal(t, reductions, results[i].reductions)
+	}
+}
+
+func BenchmarkCalculateAllUniqueVersions(b *testing.B) {
+	sch, groups := versionedSchema(200, 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		calculateAllUniqueVersions(sch, groups)
+	}
+}
+
+func TestReadSchema(t *testing.T) {
+	stdin := strings.NewReader(`{"name": "azure-native", "resources": {"azure-native:app:App": {}}}`)
+	sch, err := readSchema(stdin, "-", "", "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "azure-native", sch.Name)
+	assert.Contains(t, sch.Resources, "azure-native:app:App")
+
+	sch, err = readSchema(nil, "file:../pkg/schema.json", "test", "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", sch.Name)
+
+	_, err = readSchema(strings.NewReader("{"), "-", "", "", false)
+	assert.ErrorContains(t, err, "reading the schema from stdin")
+}
+
+func TestSqueezeJSON(t *testing.T) {
+	sch, _ := versionedSchema(1, 4)
+
+	t.Run("two resources", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		err := compareTwo(out, sch, "azure-native:mod0/v20010101:Res", "azure-native:mod0/v20020101:Res", formatJSON)
+		assert.NoError(t, err)
+		assert.Equal(t, `{
+  "old": "azure-native:mod0/v20010101:Res",
+  "new": "azure-native:mod0/v20020101:Res",
+  "violations": [
+    "Resource \"azure-native:mod0/v20020101:Res\" missing input \"prop0\""
+  ]
+}
+`, out.String())
+	})
+
+	t.Run("group", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		err := compareGroup(out, new(bytes.Buffer), sch, "mod0:Res", formatJSON, dateWindow{})
+		assert.NoError(t, err)
+		assert.Equal(t, `{
+  "all_versions": [
+    "azure-native:mod0/v20000101:Res",
+    "azure-native:mod0/v20010101:Res",
+    "azure-native:mod0/v20020101:Res",
+    "azure-native:mod0/v20030101:Res"
+  ],
+  "not_forward_compatible_versions": [
+    "azure-native:mod0/v20010101:Res",
+    "azure-native:mod0/v20030101:Res"
+  ]
+}
+`, out.String())
+	})
+
+	t.Run("all", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		err := compareAll(out, sch, "", "", formatJSON)
+		assert.NoError(t, err)
+		assert.Equal(t, `{
+  "reduced_versions": [
+    "azure-native:mod0/v20000101:Res",
+    "azure-native:mod0/v20020101:Res"
+  ],
+  "replacements": {
+    "azure-native:mod0/v20000101:Res": "azure-native:mod0/v20010101:Res",
+    "azure-native:mod0/v20020101:Res": "azure-native:mod0/v20030101:Res"
+  }
+}
+`, out.String())
+	})
+}
@@ -4,23 +4,57 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"text/tabwriter"
 
 	"github.com/pulumi/pulumi/pkg/v3/codegen"
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
 	"github.com/spf13/cobra"
 
 	"github.com/pulumi/schema-tools/internal/pkg"
 )
 
 func statsCmd() *cobra.Command {
-	var provider, repository, tag string
-	var details bool
+	var provider, repository, tag, oldRef, newRef, format, outPath string
+	var details, byModule bool
 
 	command := &cobra.Command{
 		Use:   "stats",
 		Short: "Get the stats of a current schema",
 		RunE: func(command *cobra.Command, args []string) error {
-			return stats(provider, repository, details, tag)
+			if err := validateFormat(format, formatText, formatJSON); err != nil {
+				return err
+			}
+			diff := oldRef != "" || newRef != ""
+			if diff {
+				switch {
+				case oldRef == "" || newRef == "":
+					return fmt.Errorf("--old and --new must be used together")
+				case details || byModule:
+					return fmt.Errorf(`"details" and "by-module" cannot be used with "old" and "new"`)
+				case command.Flags().Changed("format") && format != formatJSON:
+					return fmt.Errorf(`"old" and "new" only support --format %s`, formatJSON)
+				}
+			}
+
+			var out io.Writer = os.Stdout
+			if outPath != "" {
+				f, err := os.Create(outPath)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+			if diff {
+				return statsDiff(out, provider, repository, oldRef, newRef)
+			}
+			if format == formatJSON {
+				return statsSnapshot(out, provider, repository, byModule, tag)
+			}
+			return stats(out, provider, repository, details, byModule, tag)
 		},
 	}
 
@@ -34,13 +68,28 @@ func statsCmd() *cobra.Command {
 	command.Flags().BoolVarP(&details, "details", "d", false,
 		"show the details with a list of all resources and functions")
 
+	command.Flags().BoolVar(&byModule, "by-module", false,
+		"show a table of resource stats per module, worst description coverage first")
+
 	command.Flags().StringVarP(&tag, "tag", "t", "master",
 		"show the details with a list of all resources and functions")
 
+	command.Flags().StringVar(&oldRef, "old", "",
+		"the old commit to diff stats from, accepts the same values as compare's --new-commit")
+
+	command.Flags().StringVar(&newRef, "new", "",
+		"the new commit to diff stats against, accepts the same values as compare's --new-commit. "+
+			"The diff is always JSON, and can't be combined with --details or --by-module")
+
+	command.Flags().StringVar(&format, "format", formatText,
+		fmt.Sprintf("the output format, one of: %s, %s", formatText, formatJSON))
+
+	command.Flags().StringVar(&outPath, "out", "", "write the stats to this file instead of stdout")
+
 	return command
 }
 
-func stats(provider string, repositoryUrl string, details bool, tag string) error {
+func stats(out io.Writer, provider string, repositoryUrl string, details, byModule bool, tag string) error {
 	ctx := context.Background()
 	sch, err := pkg.DownloadSchema(ctx, repositoryUrl, provider, tag)
 	if err != nil {
@@ -50,21 +99,112 @@ func stats(provider string, repositoryUrl string, details bool, tag string) erro
 	schemaStats := pkg.CountStats(sch)
 
 	statsBytes, _ := json.MarshalIndent(schemaStats, "", "  ")
-	_, err = os.Stdout.Write(statsBytes)
+	_, err = out.Write(statsBytes)
 	if err != nil {
 		return fmt.Errorf("main stats: %w", err)
 	}
 
+	if byModule {
+		fmt.Fprintf(out, "\n\n### Resources by module:\n\n")
+		printModuleStats(out, pkg.StatsByModule(sch))
+	}
+
 	if details {
-		fmt.Printf("\n\n### All Resources:\n\n")
+		fmt.Fprintf(out, "\n\n### All Resources:\n\n")
 		for _, n := range codegen.SortedKeys(sch.Resources) {
-			fmt.Println(n)
+			fmt.Fprintln(out, n)
 		}
-		fmt.Printf("\n### All Functions:\n\n")
+		fmt.Fprintf(out, "\n### All Functions:\n\n")
 		for _, n := range codegen.SortedKeys(sch.Functions) {
-			fmt.Println(n)
+			fmt.Fprintln(out, n)
 		}
 	}
 
 	return nil
 }
+
+// statsSnapshotVersion is the version of the statsSnapshotReport format. Fields may be added
+// without changing it, but it must be incremented when fields are renamed, removed or change
+// meaning, so that historical snapshots remain parseable.
+const statsSnapshotVersion = 1
+
+// statsSnapshotReport is the stable JSON form of the stats of a schema, meant to be stored
+// to track the schema over time.
+type statsSnapshotReport struct {
+	Version  int                          `json:"version"`
+	Provider string                       `json:"provider"`
+	Ref      string                       `json:"ref"`
+	Stats    pkg.PulumiSchemaStats        `json:"stats"`
+	Modules  map[string]pkg.ResourceStats `json:"modules,omitempty"`
+}
+
+func statsSnapshot(out io.Writer, provider, repositoryUrl string, byModule bool, tag string) error {
+	sch, err := pkg.DownloadSchema(context.Background(), repositoryUrl, provider, tag)
+	if err != nil {
+		return err
+	}
+	return writeStatsSnapshot(out, provider, tag, sch, byModule)
+}
+
+func writeStatsSnapshot(out io.Writer, provider, ref string, sch schema.PackageSpec, byModule bool) error {
+	report := statsSnapshotReport{
+		Version:  statsSnapshotVersion,
+		Provider: provider,
+		Ref:      ref,
+		Stats:    pkg.CountStats(sch),
+	}
+	if byModule {
+		report.Modules = pkg.StatsByModule(sch)
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func statsDiff(out io.Writer, provider, repositoryUrl, oldRef, newRef string) error {
+	ctx := context.Background()
+	oldSch, err := loadSchema(ctx, repositoryUrl, provider, oldRef)
+	if err != nil {
+		return err
+	}
+	newSch, err := loadSchema(ctx, repositoryUrl, provider, newRef)
+	if err != nil {
+		return err
+	}
+
+	oldStats, newStats := pkg.CountStats(oldSch), pkg.CountStats(newSch)
+	diff := struct {
+		Old   pkg.PulumiSchemaStats `json:"old"`
+		New   pkg.PulumiSchemaStats `json:"new"`
+		Delta pkg.PulumiSchemaStats `json:"delta"`
+	}{oldStats, newStats, pkg.DiffStats(oldStats, newStats)}
+
+	statsBytes, _ := json.MarshalIndent(diff, "", "  ")
+	_, err = out.Write(statsBytes)
+	if err != nil {
+		return fmt.Errorf("stats diff: %w", err)
+	}
+	return nil
+}
+
+// printModuleStats writes a table of per-module resource stats, sorted by the number of properties
+// missing descriptions (descending) and then by module name.
+func printModuleStats(out io.Writer, modules map[string]pkg.ResourceStats) {
+	missing := func(s pkg.ResourceStats) int {
+		return s.InputPropertiesMissingDescriptions + s.OutputPropertiesMissingDescriptions
+	}
+	names := codegen.SortedKeys(modules)
+	sort.SliceStable(names, func(i, j int) bool {
+		return missing(modules[names[i]]) > missing(modules[names[j]])
+	})
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODULE\tRESOURCES\tINPUTS\tINPUTS MISSING DESC\tOUTPUTS\tOUTPUTS MISSING DESC")
+	for _, name := range names {
+		s := modules[name]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", name, s.TotalResources,
+			s.TotalInputProperties, s.InputPropertiesMissingDescriptions,
+			s.TotalOutputProperties, s.OutputPropertiesMissingDescriptions)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pulumi/schema-tools/internal/pkg"
+)
+
+func TestPrintModuleStats(t *testing.T) {
+	out := new(bytes.Buffer)
+	printModuleStats(out, map[string]pkg.ResourceStats{
+		"ec2": {TotalResources: 2, TotalInputProperties: 3, InputPropertiesMissingDescriptions: 2},
+		"iam": {TotalResources: 1, TotalOutputProperties: 1, OutputPropertiesMissingDescriptions: 1},
+		"s3":  {TotalResources: 1, TotalInputProperties: 1},
+		"sns": {TotalResources: 1, TotalInputProperties: 1, InputPropertiesMissingDescriptions: 1},
+	})
+
+	assert.Equal(t, `MODULE  RESOURCES  INPUTS  INPUTS MISSING DESC  OUTPUTS  OUTPUTS MISSING DESC
+ec2     2          3       2                    0        0
+iam     1          0       0                    1        1
+sns     1          1       1                    0        0
+s3      1          1       0                    0        0
+`, out.String())
+}
+
+func TestWriteStatsSnapshot(t *testing.T) {
+	sch := schema.PackageSpec{
+		Resources: map[string]schema.ResourceSpec{
+			"aws:s3/bucket:Bucket": {
+				InputProperties: map[string]schema.PropertySpec{
+					"acl": {},
+				},
+			},
+		},
+	}
+
+	out := new(bytes.Buffer)
+	err := writeStatsSnapshot(out, "aws", "v6.0.0", sch, true)
+	assert.NoError(t, err)
+
+	var report statsSnapshotReport
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, statsSnapshotReport{
+		Version:  1,
+		Provider: "aws",
+		Ref:      "v6.0.0",
+		Stats: pkg.PulumiSchemaStats{Resources: pkg.ResourceStats{
+			TotalResources:                     1,
+			TotalInputProperties:               1,
+			InputPropertiesMissingDescriptions: 1,
+		}},
+		Modules: map[string]pkg.ResourceStats{"s3": {
+			TotalResources:                     1,
+			TotalInputProperties:               1,
+			InputPropertiesMissingDescriptions: 1,
+		}},
+	}, report)
+	assert.Contains(t, out.String(), `"version": 1,`)
+}
+
+func TestStatsDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema := func(name string, sch schema.PackageSpec) string {
+		data, err := json.Marshal(sch)
+		assert.NoError(t, err)
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, data, 0o600))
+		return path
+	}
+	oldPath := writeSchema("old.json", schema.PackageSpec{Name: "aws"})
+	newPath := writeSchema("new.json", schema.PackageSpec{Name: "aws", Resources: map[string]schema.ResourceSpec{
+		"aws:s3/bucket:Bucket": {},
+	}})
+	outPath := filepath.Join(dir, "diff.json")
+
+	stats := func(args ...string) error {
+		command := statsCmd()
+		command.SetArgs(append([]string{"-p", "aws",
+			"--old=--local-path=" + oldPath, "--new=--local-path=" + newPath}, args...))
+		command.SilenceErrors, command.SilenceUsage = true, true
+		return command.Execute()
+	}
+
+	assert.NoError(t, stats("--out", outPath))
+	var diff struct {
+		Delta pkg.PulumiSchemaStats `json:"delta"`
+	}
+	data, err := os.ReadFile(outPath)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &diff))
+	assert.Equal(t, 1, diff.Delta.Resources.TotalResources)
+
+	assert.EqualError(t, stats("--details"), `"details" and "by-module" cannot be used with "old" and "new"`)
+	assert.EqualError(t, stats("--format", "text"), `"old" and "new" only support --format json`)
+}
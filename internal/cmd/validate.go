@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulumi/schema-tools/internal/pkg"
+)
+
+func validateCmd() *cobra.Command {
+	var provider, repository, commit string
+	var orphans bool
+
+	command := &cobra.Command{
+		Use:   "validate",
+		Short: "Check a Pulumi schema for references that don't resolve",
+		RunE: func(command *cobra.Command, args []string) error {
+			return validate(provider, repository, commit, orphans)
+		},
+	}
+
+	command.Flags().StringVarP(&provider, "provider", "p", "",
+		"the provider whose schema we should validate")
+	_ = command.MarkFlagRequired("provider")
+
+	command.Flags().StringVarP(&repository, "repository", "r", "github://api.github.com/pulumi",
+		"the Git repository to download the schema file from")
+
+	command.Flags().StringVarP(&commit, "commit", "c", "master",
+		"the commit to validate, accepts the same values as compare's --new-commit")
+
+	command.Flags().BoolVar(&orphans, "orphans", false,
+		"also list types that are not reachable from any resource, function, the provider or config")
+
+	return command
+}
+
+func validate(provider, repository, commit string, orphans bool) error {
+	sch, err := loadSchema(context.Background(), repository, provider, commit)
+	if err != nil {
+		return err
+	}
+
+	if orphans {
+		unreferenced := pkg.UnreferencedTypes(sch)
+		if len(unreferenced) > 0 {
+			fmt.Printf("Found %d unreferenced type(s):\n", len(unreferenced))
+			for _, token := range unreferenced {
+				fmt.Printf("  %s\n", token)
+			}
+			fmt.Println()
+		}
+	}
+
+	refErrs := pkg.ValidateRefs(sch)
+	for _, e := range refErrs {
+		fmt.Println(e.Error())
+	}
+
+	switch len(refErrs) {
+	case 0:
+		fmt.Println("Looking good! All references resolve.")
+		return nil
+	case 1:
+		return fmt.Errorf("found 1 dangling reference")
+	default:
+		return fmt.Errorf("found %d dangling references", len(refErrs))
+	}
+}
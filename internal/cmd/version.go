@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"runtime"
 
 	"github.com/pulumi/schema-tools/version"
 	"github.com/spf13/cobra"
@@ -12,7 +13,8 @@ func versionCmd() *cobra.Command {
 		Use:   "version",
 		Short: "Print the version number of schema-tools",
 		Run: func(command *cobra.Command, args []string) {
-			fmt.Println(version.Version)
+			fmt.Printf("schema-tools %s (%s, %s/%s)\n",
+				version.Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
 		},
 	}
 }
@@ -0,0 +1,125 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/contract"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/logging"
+)
+
+// commentsPerPage is the most comments GitHub lists per request.
+const commentsPerPage = 100
+
+// maxErrorBody bounds how much of a failed response's body is quoted in its error.
+const maxErrorBody = 1024
+
+// UpsertPRComment posts body as a comment on pull request pr of the GitHub repository repo, e.g.
+// "pulumi/pulumi-aws", served by the REST API at host, e.g. "api.github.com" or
+// "ghe.mycorp.com/api/v3". The first comment containing marker, typically a hidden HTML comment,
+// is updated instead if there is one, so that re-running a CI job doesn't pile up comments. Body
+// should contain marker for later runs to find it.
+//
+// Uses the GITHUB_TOKEN or else the GH_TOKEN environment variable for authentication.
+func UpsertPRComment(ctx context.Context, host, repo string, pr int, marker, body string) error {
+	if strings.Count(repo, "/") != 1 {
+		return fmt.Errorf("repository must have the format <owner>/<name>, was: %s", repo)
+	}
+	host, prefix, _ := strings.Cut(host, "/")
+	source, err := newGithubSource(&url.URL{Scheme: "github", Host: host, Path: path.Join("/", prefix, repo)}, "")
+	if err != nil {
+		return err
+	}
+	if source.token == "" {
+		return errors.New("commenting on a pull request requires a token in GITHUB_TOKEN or GH_TOKEN")
+	}
+	api := fmt.Sprintf("https://%s%s/repos/%s/%s",
+		source.host, source.apiPrefix, source.organization, source.repository)
+	payload := map[string]string{"body": body}
+
+	for page := 1; ; page++ {
+		var comments []struct {
+			ID   int64  `json:"id"`
+			Body string `json:"body"`
+		}
+		url := fmt.Sprintf("%s/issues/%d/comments?per_page=%d&page=%d", api, pr, commentsPerPage, page)
+		if err := source.apiRequest(ctx, http.MethodGet, url, nil, &comments); err != nil {
+			return err
+		}
+		for _, comment := range comments {
+			if strings.Contains(comment.Body, marker) {
+				logging.V(1).Infof("updating comment %d on %s#%d", comment.ID, repo, pr)
+				url := fmt.Sprintf("%s/issues/comments/%d", api, comment.ID)
+				return source.apiRequest(ctx, http.MethodPatch, url, payload, nil)
+			}
+		}
+		if len(comments) < commentsPerPage {
+			break
+		}
+	}
+
+	logging.V(1).Infof("commenting on %s#%d", repo, pr)
+	url := fmt.Sprintf("%s/issues/%d/comments", api, pr)
+	return source.apiRequest(ctx, http.MethodPost, url, payload, nil)
+}
+
+// apiRequest sends payload, if any, as JSON to the GitHub REST API and decodes the response into
+// result, if any.
+func (source *githubSource) apiRequest(ctx context.Context, method, url string, payload, result any) error {
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := source.newAPIRequest(ctx, method, url, "application/vnd.github+json", reqBody)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, _, err := source.getHTTPResponse(getAPIResponse, req)
+	if err != nil {
+		return err
+	}
+	defer contract.IgnoreClose(resp)
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp).Decode(result)
+}
+
+// getAPIResponse is getHTTPResponse for REST API calls, whose errors name the method and quote
+// the start of the response body, where GitHub explains what went wrong.
+func getAPIResponse(req *http.Request) (io.ReadCloser, int64, error) {
+	logging.V(9).Infof("GitHub API request: %s %s", req.Method, req.URL)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer contract.IgnoreClose(resp.Body)
+		msg := fmt.Sprintf("%d HTTP error from %s %s", resp.StatusCode, req.Method, req.URL)
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		if body := strings.TrimSpace(string(body)); body != "" {
+			msg += ": " + body
+		}
+		return nil, -1, &downloadError{code: resp.StatusCode, msg: msg, header: resp.Header}
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
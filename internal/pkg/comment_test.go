@@ -0,0 +1,96 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpsertPRComment(t *testing.T) {
+	const marker = "<!-- schema-tools compare aws -->"
+	body := marker + "\nLooking good! No breaking changes found."
+
+	t.Run("new comment", func(t *testing.T) {
+		defer gock.Off()
+		t.Setenv("GITHUB_TOKEN", "github-token")
+
+		gock.New("https://api.github.com").
+			Get("/repos/pulumi/pulumi-aws/issues/12/comments").
+			MatchParam("page", "1").
+			Reply(200).
+			JSON([]map[string]any{{"id": 1, "body": "LGTM"}})
+		gock.New("https://api.github.com").
+			Post("/repos/pulumi/pulumi-aws/issues/12/comments").
+			MatchHeader("Authorization", "token github-token").
+			JSON(map[string]string{"body": body}).
+			Reply(201)
+
+		err := UpsertPRComment(context.Background(), "api.github.com", "pulumi/pulumi-aws", 12, marker, body)
+		assert.Nil(t, err)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("existing comment", func(t *testing.T) {
+		defer gock.Off()
+		t.Setenv("GITHUB_TOKEN", "github-token")
+
+		gock.New("https://api.github.com").
+			Get("/repos/pulumi/pulumi-aws/issues/12/comments").
+			MatchParam("page", "1").
+			Reply(200).
+			JSON([]map[string]any{{"id": 1, "body": "LGTM"}, {"id": 2, "body": marker + "\nFound 1 breaking change"}})
+		gock.New("https://api.github.com").
+			Patch("/repos/pulumi/pulumi-aws/issues/comments/2").
+			JSON(map[string]string{"body": body}).
+			Reply(200)
+
+		err := UpsertPRComment(context.Background(), "api.github.com", "pulumi/pulumi-aws", 12, marker, body)
+		assert.Nil(t, err)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("GitHub Enterprise Server", func(t *testing.T) {
+		defer gock.Off()
+		t.Setenv("GITHUB_TOKEN", "github-token")
+
+		gock.New("https://ghe.mycorp.com").
+			Get("/api/v3/repos/pulumi/pulumi-aws/issues/12/comments").
+			Reply(200).
+			JSON([]map[string]any{})
+		gock.New("https://ghe.mycorp.com").
+			Post("/api/v3/repos/pulumi/pulumi-aws/issues/12/comments").
+			Reply(201)
+
+		err := UpsertPRComment(context.Background(), "ghe.mycorp.com", "pulumi/pulumi-aws", 12, marker, body)
+		assert.Nil(t, err)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer gock.Off()
+		t.Setenv("GITHUB_TOKEN", "github-token")
+
+		gock.New("https://api.github.com").
+			Get("/repos/pulumi/pulumi-aws/issues/12/comments").
+			Reply(200).
+			JSON([]map[string]any{})
+		gock.New("https://api.github.com").
+			Post("/repos/pulumi/pulumi-aws/issues/12/comments").
+			Reply(403).
+			JSON(map[string]string{"message": "Resource not accessible by integration"})
+
+		err := UpsertPRComment(context.Background(), "api.github.com", "pulumi/pulumi-aws", 12, marker, body)
+		assert.EqualError(t, err, `403 HTTP error from POST https://api.github.com/repos/pulumi/pulumi-aws/issues/12/comments: `+
+			`{"message":"Resource not accessible by integration"}`)
+	})
+
+	t.Run("no token", func(t *testing.T) {
+		t.Setenv("GITHUB_TOKEN", "")
+		t.Setenv("GH_TOKEN", "")
+
+		err := UpsertPRComment(context.Background(), "api.github.com", "pulumi/pulumi-aws", 12, marker, body)
+		assert.EqualError(t, err, "commenting on a pull request requires a token in GITHUB_TOKEN or GH_TOKEN")
+	})
+}
@@ -34,10 +34,13 @@ type gitlabSource struct {
 	name    string
 
 	token string
+	// jobToken is set when token is a GitLab CI job token, which isn't sent as a bearer token.
+	jobToken bool
 }
 
 // Creates a new GitLab source from a gitlab://<host>/<project_id> url.
-// Uses the GITLAB_TOKEN environment variable for authentication if it's set.
+// Uses the GITLAB_TOKEN environment variable for authentication if it's set, or else the
+// CI_JOB_TOKEN that GitLab CI sets for its jobs.
 func newGitlabSource(url *url.URL, name string) (*gitlabSource, error) {
 	contract.Requiref(url.Scheme == "gitlab", "url", `scheme must be "gitlab", was %q`, url.Scheme)
 
@@ -66,19 +69,24 @@ func newGitlabSource(url *url.URL, name string) (*gitlabSource, error) {
 		repository = parts[1]
 	}
 
-	return &gitlabSource{
+	source := &gitlabSource{
 		host:    host,
 		owner:   owner,
 		project: repository,
 		name:    name,
 
 		token: os.Getenv("GITLAB_TOKEN"),
-	}, nil
+	}
+	if source.token == "" {
+		source.token = os.Getenv("CI_JOB_TOKEN")
+		source.jobToken = source.token != ""
+	}
+	return source, nil
 }
 
 func (source *gitlabSource) newHTTPRequest(ctx context.Context, url, accept string) (*http.Request, error) {
 	var authorization string
-	if source.token != "" {
+	if source.token != "" && !source.jobToken {
 		authorization = fmt.Sprintf("Bearer %s", source.token)
 	}
 
@@ -86,6 +94,9 @@ func (source *gitlabSource) newHTTPRequest(ctx context.Context, url, accept stri
 	if err != nil {
 		return nil, err
 	}
+	if source.jobToken {
+		req.Header.Set("JOB-TOKEN", source.token)
+	}
 	req.Header.Set("Accept", accept)
 	return req, nil
 }
@@ -110,9 +121,14 @@ func (source *gitlabSource) Download(
 	return getHTTPResponse(req)
 }
 
+// githubAPIHost is the REST API host of github.com. Any other host is assumed to be a GitHub
+// Enterprise Server instance.
+const githubAPIHost = "api.github.com"
+
 // githubSource can download a plugin from github releases
 type githubSource struct {
 	host         string
+	apiPrefix    string
 	organization string
 	repository   string
 	name         string
@@ -120,7 +136,12 @@ type githubSource struct {
 	token string
 }
 
-// Creates a new github source adding authentication data in the environment, if it exists
+// Creates a new github source adding authentication data in the environment, if it exists:
+// GITHUB_TOKEN, or else GH_TOKEN as set for the GitHub CLI.
+//
+// Hosts other than api.github.com are treated as GitHub Enterprise Server instances, which serve
+// their REST API under /api/v3. The prefix may also be spelled out explicitly in the url, e.g.
+// github://ghe.mycorp.com/api/v3/<organization>[/<repository>].
 func newGithubSource(url *url.URL, name string) (*githubSource, error) {
 	contract.Requiref(url.Scheme == "github", "url", `scheme must be "github", was %q`, url.Scheme)
 
@@ -131,6 +152,14 @@ func newGithubSource(url *url.URL, name string) (*githubSource, error) {
 		return nil, fmt.Errorf("github:// url must have a host part, was: %s", url)
 	}
 
+	var apiPrefix string
+	if len(parts) >= 2 && parts[0] == "api" && parts[1] == "v3" {
+		apiPrefix = "/api/v3"
+		parts = parts[2:]
+	} else if host != githubAPIHost {
+		apiPrefix = "/api/v3"
+	}
+
 	if len(parts) != 1 && len(parts) != 2 {
 		return nil, fmt.Errorf(
 			"github:// url must have the format <host>/<organization>[/<repository>], was: %s",
@@ -151,21 +180,29 @@ func newGithubSource(url *url.URL, name string) (*githubSource, error) {
 
 	return &githubSource{
 		host:         host,
+		apiPrefix:    apiPrefix,
 		organization: organization,
 		repository:   repository,
 		name:         name,
 
-		token: os.Getenv("GITHUB_TOKEN"),
+		token: firstEnv("GITHUB_TOKEN", "GH_TOKEN"),
 	}, nil
 }
 
 func (source *githubSource) newHTTPRequest(ctx context.Context, url, accept string) (*http.Request, error) {
+	return source.newAPIRequest(ctx, http.MethodGet, url, accept, nil)
+}
+
+// newAPIRequest is newHTTPRequest for any method, sending body if it isn't nil.
+func (source *githubSource) newAPIRequest(
+	ctx context.Context, method, url, accept string, body io.Reader,
+) (*http.Request, error) {
 	var authorization string
 	if source.token != "" {
 		authorization = fmt.Sprintf("token %s", source.token)
 	}
 
-	req, err := buildHTTPRequest(ctx, url, authorization)
+	req, err := buildHTTPRequestWithBody(ctx, method, url, body, authorization)
 	if err != nil {
 		return nil, err
 	}
@@ -182,9 +219,28 @@ func (source *githubSource) getHTTPResponse(
 		return resp, length, nil
 	}
 
-	// Wrap 403 rate limit errors with a more helpful message.
 	var downErr *downloadError
-	if !errors.As(err, &downErr) || downErr.code != 403 {
+	if !errors.As(err, &downErr) {
+		return nil, -1, err
+	}
+
+	// GitHub answers 404 for private repositories when the request is unauthenticated, so
+	// point the user at GITHUB_TOKEN regardless of which GitHub host we are talking to, unless
+	// we can tell that the repository is visible and it is the ref that doesn't exist.
+	if downErr.code == 404 {
+		ref := req.URL.Query().Get("ref")
+		if ref != "" && source.refNotFound(req.Context(), getHTTPResponse, ref) {
+			return nil, -1, &downloadError{
+				code: downErr.code,
+				msg: fmt.Sprintf("ref %q not found in %s/%s: check that the branch, tag or commit exists",
+					ref, source.organization, source.repository),
+			}
+		}
+		return nil, -1, newGithubPrivateRepoError(downErr)
+	}
+
+	// Wrap 403 rate limit errors with a more helpful message.
+	if downErr.code != 403 {
 		return nil, -1, err
 	}
 
@@ -209,13 +265,42 @@ func (source *githubSource) getHTTPResponse(
 	return nil, -1, fmt.Errorf("rate limit exceeded: %w", err)
 }
 
+// refNotFound reports whether the repository is visible to us but ref doesn't name a branch, tag
+// or commit in it. It returns false whenever it can't tell.
+func (source *githubSource) refNotFound(
+	ctx context.Context, getHTTPResponse func(*http.Request) (io.ReadCloser, int64, error), ref string,
+) bool {
+	repoURL := fmt.Sprintf("https://%s%s/repos/%s/%s",
+		source.host, source.apiPrefix, source.organization, source.repository)
+	probe := func(endpoint string) error {
+		req, err := source.newHTTPRequest(ctx, endpoint, "application/vnd.github+json")
+		if err != nil {
+			return err
+		}
+		resp, _, err := getHTTPResponse(req)
+		if err == nil {
+			contract.IgnoreClose(resp)
+		}
+		return err
+	}
+
+	if err := probe(repoURL); err != nil {
+		return false
+	}
+	// The commits endpoint resolves branches, tags and full or short SHAs alike. It answers 422
+	// rather than 404 for a ref that isn't a valid SHA.
+	var downErr *downloadError
+	err := probe(repoURL + "/commits/" + url.PathEscape(ref))
+	return errors.As(err, &downErr) && (downErr.code == 404 || downErr.code == 422)
+}
+
 func (source *githubSource) Download(
 	ctx context.Context, commit string,
 	getHTTPResponse func(*http.Request) (io.ReadCloser, int64, error),
 ) (io.ReadCloser, int64, error) {
 	schemaURL := fmt.Sprintf(
-		"https://%s/repos/%s/%s/contents/%s?ref=%s",
-		source.host, source.organization, source.repository, StandardSchemaPath(source.name), commit)
+		"https://%s%s/repos/%s/%s/contents/%s?ref=%s",
+		source.host, source.apiPrefix, source.organization, source.repository, StandardSchemaPath(source.name), commit)
 	logging.V(9).Infof("plugin GitHub schema url: %s", schemaURL)
 
 	req, err := source.newHTTPRequest(ctx, schemaURL, "application/vnd.github.v4.raw")
@@ -225,8 +310,24 @@ func (source *githubSource) Download(
 	return source.getHTTPResponse(getHTTPResponse, req)
 }
 
+// firstEnv returns the value of the first of the environment variables names that is set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
 func buildHTTPRequest(ctx context.Context, pluginEndpoint string, authorization string) (*http.Request, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", pluginEndpoint, nil)
+	return buildHTTPRequestWithBody(ctx, http.MethodGet, pluginEndpoint, nil, authorization)
+}
+
+func buildHTTPRequestWithBody(
+	ctx context.Context, method, endpoint string, body io.Reader, authorization string,
+) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
 	if err != nil {
 		return nil, err
 	}
@@ -298,23 +399,20 @@ func (e *downloadError) Error() string {
 	return e.msg
 }
 
-// Create a new downloadError with a message that indicates GITHUB_TOKEN should be set.
-func newGithubPrivateRepoError(statusCode int, url *url.URL) error {
+// Create a new downloadError from err with a message that indicates GITHUB_TOKEN should be set.
+func newGithubPrivateRepoError(err *downloadError) error {
 	return &downloadError{
-		code: statusCode,
-		msg: fmt.Sprintf("%d HTTP error fetching schema from %s. "+
+		code: err.code,
+		msg: fmt.Sprintf("%s. "+
 			"If this is a private GitHub repository, try "+
 			"providing a token via the GITHUB_TOKEN environment variable. "+
 			"See: https://github.com/settings/tokens",
-			statusCode, url),
+			err.msg),
 	}
 }
 
 // Create a new downloadError.
 func newDownloadError(statusCode int, url *url.URL, header http.Header) error {
-	if url.Host == "api.github.com" && statusCode == 404 {
-		return newGithubPrivateRepoError(statusCode, url)
-	}
 	return &downloadError{
 		code:   statusCode,
 		msg:    fmt.Sprintf("%d HTTP error fetching schema from %s", statusCode, url),
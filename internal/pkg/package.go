@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/contract"
+)
+
+// PackageDescriptor identifies a published version of a Pulumi package, e.g. aws@6.0.0.
+type PackageDescriptor struct {
+	Name    string
+	Version string
+}
+
+// ParsePackageDescriptor parses a descriptor of the form <name>@<version>. A leading "v" in
+// the version is dropped, so that v6.0.0 and 6.0.0 name the same version.
+func ParsePackageDescriptor(s string) (PackageDescriptor, error) {
+	name, version, ok := strings.Cut(s, "@")
+	version = strings.TrimPrefix(version, "v")
+	if !ok || name == "" || version == "" {
+		return PackageDescriptor{}, fmt.Errorf("package must have the format <name>@<version>, was: %q", s)
+	}
+	return PackageDescriptor{Name: name, Version: version}, nil
+}
+
+func (d PackageDescriptor) String() string {
+	return d.Name + "@" + d.Version
+}
+
+// registryAPI is the Pulumi Registry API, which knows where the schema of each published version
+// of a package is hosted.
+const registryAPI = "https://api.pulumi.com/api/registry"
+
+// DownloadPackageSchema fetches the schema of the package described by d, as published by Pulumi
+// to the Pulumi Registry. The registry points at the schema on the Pulumi CDN, which is then
+// downloaded like a schema from a GitSource.
+func DownloadPackageSchema(ctx context.Context, d PackageDescriptor) (schema.PackageSpec, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DownloadTimeout)
+		defer cancel()
+	}
+
+	schemaURL, err := packageSchemaURL(ctx, d)
+	if err != nil {
+		return schema.PackageSpec{}, fmt.Errorf("resolving the schema of %s: %w", d, err)
+	}
+
+	req, err := buildHTTPRequest(ctx, schemaURL, "")
+	if err != nil {
+		return schema.PackageSpec{}, err
+	}
+	body, _, err := getHTTPResponse(req)
+	if err != nil {
+		return schema.PackageSpec{}, err
+	}
+	defer contract.IgnoreClose(body)
+
+	sch, err := LoadPackageSpec(body)
+	if err != nil {
+		return schema.PackageSpec{}, fmt.Errorf("decoding the schema of %s: %w", d, err)
+	}
+	return sch, nil
+}
+
+// packageSchemaURL asks the Pulumi Registry where the schema of d is hosted.
+func packageSchemaURL(ctx context.Context, d PackageDescriptor) (string, error) {
+	endpoint := fmt.Sprintf("%s/packages/pulumi/pulumi/%s/versions/%s",
+		registryAPI, url.PathEscape(d.Name), url.PathEscape(d.Version))
+	req, err := buildHTTPRequest(ctx, endpoint, "")
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	body, _, err := getHTTPResponse(req)
+	if err != nil {
+		return "", err
+	}
+	defer contract.IgnoreClose(body)
+
+	var metadata struct {
+		SchemaURL string `json:"schemaURL"`
+	}
+	if err := json.NewDecoder(body).Decode(&metadata); err != nil {
+		return "", fmt.Errorf("decoding the registry metadata: %w", err)
+	}
+	if metadata.SchemaURL == "" {
+		return "", errors.New("the registry doesn't list a schema")
+	}
+	return metadata.SchemaURL, nil
+}
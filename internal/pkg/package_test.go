@@ -0,0 +1,55 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePackageDescriptor(t *testing.T) {
+	d, err := ParsePackageDescriptor("aws@6.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, PackageDescriptor{Name: "aws", Version: "6.0.0"}, d)
+	assert.Equal(t, "aws@6.0.0", d.String())
+
+	d, err = ParsePackageDescriptor("aws@v6.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, PackageDescriptor{Name: "aws", Version: "6.0.0"}, d)
+
+	for _, s := range []string{"", "aws", "aws@", "@6.0.0"} {
+		_, err := ParsePackageDescriptor(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestDownloadPackageSchema(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.pulumi.com").
+		Get("/api/registry/packages/pulumi/pulumi/unifi/versions/1.0.0").
+		Reply(200).
+		JSON(map[string]string{"schemaURL": "https://artifacts.pulumi.com/providers/unifi/schema.json"})
+	gock.New("https://artifacts.pulumi.com").
+		Get("/providers/unifi/schema.json").
+		Reply(200).
+		File("schema.json")
+
+	spec, err := DownloadPackageSchema(context.Background(), PackageDescriptor{Name: "unifi", Version: "1.0.0"})
+	assert.NoError(t, err)
+	assert.Equal(t, "test", spec.Name)
+	assert.True(t, gock.IsDone())
+}
+
+func TestDownloadUnknownPackageSchema(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.pulumi.com").
+		Get("/api/registry/packages/pulumi/pulumi/unifi/versions/2.0.0").
+		Reply(404)
+
+	_, err := DownloadPackageSchema(context.Background(), PackageDescriptor{Name: "unifi", Version: "2.0.0"})
+	assert.EqualError(t, err, "resolving the schema of unifi@2.0.0: 404 HTTP error fetching schema from "+
+		"https://api.pulumi.com/api/registry/packages/pulumi/pulumi/unifi/versions/2.0.0")
+}
@@ -0,0 +1,223 @@
+package pkg
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen"
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+)
+
+// RefError describes a reference to a type that is not defined in the schema.
+type RefError struct {
+	// Location is where the reference was found, e.g. "resources.aws:s3/bucket:Bucket.inputProperties.acl".
+	Location string
+	// Ref is the reference that does not resolve.
+	Ref string
+}
+
+func (e RefError) Error() string {
+	return fmt.Sprintf("%s: %q does not resolve to a type in the schema", e.Location, e.Ref)
+}
+
+// ValidateRefs returns a RefError for every local #/types/ reference in the schema that doesn't resolve to one of
+// its types, sorted by location.
+func ValidateRefs(sch schema.PackageSpec) []RefError {
+	var errs []RefError
+	walkRefs(sch, func(location, ref string) {
+		token, ok := LocalTypeToken(ref)
+		if !ok {
+			return
+		}
+		if _, ok := sch.Types[token]; !ok {
+			errs = append(errs, RefError{Location: location, Ref: ref})
+		}
+	})
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Location < errs[j].Location })
+	return errs
+}
+
+// LocalTypeToken returns the token of the type a reference points to, if the reference points into the types of
+// the same schema.
+func LocalTypeToken(ref string) (string, bool) {
+	const prefix = "#/types/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", false
+	}
+	token := strings.TrimPrefix(ref, prefix)
+	// Tokens may have their slashes escaped, e.g. "aws:s3%2Fbucket:Bucket".
+	if unescaped, err := url.PathUnescape(token); err == nil {
+		token = unescaped
+	}
+	return token, true
+}
+
+// UnreferencedTypes returns the sorted tokens of the types that cannot be reached from any resource, function,
+// the provider or its config, directly or through other types.
+func UnreferencedTypes(sch schema.PackageSpec) []string {
+	reached := map[string]bool{}
+	var queue []string
+	visit := func(_, ref string) {
+		token, ok := LocalTypeToken(ref)
+		if !ok || reached[token] {
+			return
+		}
+		reached[token] = true
+		queue = append(queue, token)
+	}
+
+	walkRootRefs(sch, visit)
+	for len(queue) > 0 {
+		token := queue[0]
+		queue = queue[1:]
+		if t, ok := sch.Types[token]; ok {
+			walkPropertyRefs("types."+token+".properties", t.Properties, visit)
+		}
+	}
+
+	var unreferenced []string
+	for _, token := range codegen.SortedKeys(sch.Types) {
+		if !reached[token] {
+			unreferenced = append(unreferenced, token)
+		}
+	}
+	return unreferenced
+}
+
+// TypeCycles returns the groups of types that reference each other, directly or through other
+// types, such as a type with a property of its own type. Each group is sorted, and the groups
+// are sorted by their first token.
+func TypeCycles(sch schema.PackageSpec) [][]string {
+	graph := map[string][]string{}
+	for _, token := range codegen.SortedKeys(sch.Types) {
+		walkPropertyRefs("", sch.Types[token].Properties, func(_, ref string) {
+			if target, ok := LocalTypeToken(ref); ok {
+				graph[token] = append(graph[token], target)
+			}
+		})
+	}
+
+	// Tarjan's algorithm: each strongly connected component with more than one type, or with a
+	// type that references itself, is a cycle.
+	index, lowlink := map[string]int{}, map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var cycles [][]string
+	var connect func(token string)
+	connect = func(token string) {
+		index[token], lowlink[token] = len(index), len(index)
+		stack = append(stack, token)
+		onStack[token] = true
+
+		selfRef := false
+		for _, target := range graph[token] {
+			if target == token {
+				selfRef = true
+			}
+			if _, visited := index[target]; !visited {
+				connect(target)
+				lowlink[token] = min(lowlink[token], lowlink[target])
+			} else if onStack[target] {
+				lowlink[token] = min(lowlink[token], index[target])
+			}
+		}
+
+		if lowlink[token] != index[token] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == token {
+				break
+			}
+		}
+		if len(component) > 1 || selfRef {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+	for _, token := range codegen.SortedKeys(sch.Types) {
+		if _, visited := index[token]; !visited {
+			connect(token)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// walkRefs calls visit with every reference in the schema, along with its location. Schema sections are
+// visited in a deterministic order.
+func walkRefs(sch schema.PackageSpec, visit func(location, ref string)) {
+	walkRootRefs(sch, visit)
+	for _, token := range codegen.SortedKeys(sch.Types) {
+		walkPropertyRefs("types."+token+".properties", sch.Types[token].Properties, visit)
+	}
+}
+
+// walkRootRefs is like walkRefs, but skips the types section: it only visits the references that are used
+// directly by resources, functions, the provider and its config.
+func walkRootRefs(sch schema.PackageSpec, visit func(location, ref string)) {
+	for _, token := range codegen.SortedKeys(sch.Resources) {
+		r := sch.Resources[token]
+		location := "resources." + token
+		walkPropertyRefs(location+".inputProperties", r.InputProperties, visit)
+		walkPropertyRefs(location+".properties", r.Properties, visit)
+		if r.StateInputs != nil {
+			walkPropertyRefs(location+".stateInputs", r.StateInputs.Properties, visit)
+		}
+	}
+
+	for _, token := range codegen.SortedKeys(sch.Functions) {
+		f := sch.Functions[token]
+		location := "functions." + token
+		if f.Inputs != nil {
+			walkPropertyRefs(location+".inputs", f.Inputs.Properties, visit)
+		}
+		if f.Outputs != nil {
+			walkPropertyRefs(location+".outputs", f.Outputs.Properties, visit)
+		}
+		if f.ReturnType != nil {
+			if f.ReturnType.ObjectTypeSpec != nil {
+				walkPropertyRefs(location+".outputs", f.ReturnType.ObjectTypeSpec.Properties, visit)
+			}
+			walkTypeRefs(location+".outputs", f.ReturnType.TypeSpec, visit)
+		}
+	}
+
+	walkPropertyRefs("provider.inputProperties", sch.Provider.InputProperties, visit)
+	walkPropertyRefs("provider.properties", sch.Provider.Properties, visit)
+	walkPropertyRefs("config.variables", sch.Config.Variables, visit)
+}
+
+func walkPropertyRefs(location string, props map[string]schema.PropertySpec, visit func(location, ref string)) {
+	for _, name := range codegen.SortedKeys(props) {
+		prop := props[name]
+		walkTypeRefs(location+"."+name, &prop.TypeSpec, visit)
+	}
+}
+
+func walkTypeRefs(location string, ts *schema.TypeSpec, visit func(location, ref string)) {
+	if ts == nil {
+		return
+	}
+	if ts.Ref != "" {
+		visit(location, ts.Ref)
+	}
+	walkTypeRefs(location+".items", ts.Items, visit)
+	walkTypeRefs(location+".additionalProperties", ts.AdditionalProperties, visit)
+	for i := range ts.OneOf {
+		walkTypeRefs(fmt.Sprintf("%s.oneOf[%d]", location, i), &ts.OneOf[i], visit)
+	}
+	if ts.Discriminator != nil {
+		for _, key := range codegen.SortedKeys(ts.Discriminator.Mapping) {
+			visit(location+".discriminator.mapping."+key, ts.Discriminator.Mapping[key])
+		}
+	}
+}
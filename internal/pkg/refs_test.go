@@ -0,0 +1,139 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRefs(t *testing.T) {
+	ref := func(token string) schema.TypeSpec {
+		return schema.TypeSpec{Ref: "#/types/" + token}
+	}
+	testSchema := schema.PackageSpec{
+		Types: map[string]schema.ComplexTypeSpec{
+			"test:index/good:Good": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"self": {TypeSpec: ref("test:index/good:Good")},
+						"missing": {TypeSpec: schema.TypeSpec{
+							Type:  "array",
+							Items: &schema.TypeSpec{Type: "object", AdditionalProperties: ptr(ref("test:index/gone:Gone"))},
+						}},
+					},
+				},
+			},
+		},
+		Resources: map[string]schema.ResourceSpec{
+			"test:index/foo:Foo": {
+				InputProperties: map[string]schema.PropertySpec{
+					"escaped":  {TypeSpec: ref("test:index%2Fgood:Good")},
+					"external": {TypeSpec: schema.TypeSpec{Ref: "/aws/v5.4.0/schema.json#/types/aws:s3:Bucket"}},
+					"any":      {TypeSpec: schema.TypeSpec{Ref: "pulumi.json#/Any"}},
+					"union": {TypeSpec: schema.TypeSpec{
+						OneOf: []schema.TypeSpec{ref("test:index/good:Good"), ref("test:index/cat:Cat")},
+					}},
+				},
+			},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			"test:index/getFoo:getFoo": {
+				Outputs: &schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"result": {TypeSpec: ref("test:index/result:Result")},
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []RefError{
+		{
+			Location: "functions.test:index/getFoo:getFoo.outputs.result",
+			Ref:      "#/types/test:index/result:Result",
+		},
+		{
+			Location: "resources.test:index/foo:Foo.inputProperties.union.oneOf[1]",
+			Ref:      "#/types/test:index/cat:Cat",
+		},
+		{
+			Location: "types.test:index/good:Good.properties.missing.items.additionalProperties",
+			Ref:      "#/types/test:index/gone:Gone",
+		},
+	}, ValidateRefs(testSchema))
+}
+
+func TestUnreferencedTypes(t *testing.T) {
+	ref := func(token string) schema.PropertySpec {
+		return schema.PropertySpec{TypeSpec: schema.TypeSpec{Ref: "#/types/" + token}}
+	}
+	object := func(props map[string]schema.PropertySpec) schema.ComplexTypeSpec {
+		return schema.ComplexTypeSpec{ObjectTypeSpec: schema.ObjectTypeSpec{Properties: props}}
+	}
+	testSchema := schema.PackageSpec{
+		Types: map[string]schema.ComplexTypeSpec{
+			"test:index:Direct": object(map[string]schema.PropertySpec{
+				"nested": ref("test:index:Nested"),
+			}),
+			"test:index:Nested": object(map[string]schema.PropertySpec{
+				"pet": {TypeSpec: schema.TypeSpec{
+					OneOf: []schema.TypeSpec{{Ref: "#/types/test:index:Cat"}},
+					Discriminator: &schema.DiscriminatorSpec{
+						PropertyName: "kind",
+						Mapping:      map[string]string{"dog": "#/types/test:index:Dog"},
+					},
+				}},
+			}),
+			"test:index:Cat":    object(nil),
+			"test:index:Dog":    object(nil),
+			"test:index:Config": object(nil),
+			"test:index:Orphan": object(map[string]schema.PropertySpec{
+				"child": ref("test:index:OrphanChild"),
+			}),
+			"test:index:OrphanChild": object(nil),
+		},
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Foo": {
+				InputProperties: map[string]schema.PropertySpec{"direct": ref("test:index:Direct")},
+			},
+		},
+		Config: schema.ConfigSpec{
+			Variables: map[string]schema.PropertySpec{"settings": ref("test:index:Config")},
+		},
+	}
+
+	assert.Equal(t, []string{"test:index:Orphan", "test:index:OrphanChild"}, UnreferencedTypes(testSchema))
+}
+
+func TestTypeCycles(t *testing.T) {
+	object := func(refs ...string) schema.ComplexTypeSpec {
+		props := map[string]schema.PropertySpec{}
+		for _, ref := range refs {
+			props[ref] = schema.PropertySpec{TypeSpec: schema.TypeSpec{
+				Type:  "array",
+				Items: &schema.TypeSpec{Ref: "#/types/test:index:" + ref},
+			}}
+		}
+		return schema.ComplexTypeSpec{ObjectTypeSpec: schema.ObjectTypeSpec{Type: "object", Properties: props}}
+	}
+	sch := schema.PackageSpec{
+		Types: map[string]schema.ComplexTypeSpec{
+			"test:index:A":    object("B"),
+			"test:index:B":    object("C", "Leaf"),
+			"test:index:C":    object("A"),
+			"test:index:Leaf": object(),
+			"test:index:Self": object("Self", "Leaf"),
+			"test:index:Root": object("A", "Self", "Gone"),
+		},
+	}
+
+	assert.Equal(t, [][]string{
+		{"test:index:A", "test:index:B", "test:index:C"},
+		{"test:index:Self"},
+	}, TypeCycles(sch))
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -6,6 +6,7 @@ import (
 
 	mapset "github.com/deckarep/golang-set/v2"
 
+	"github.com/pulumi/pulumi/pkg/v3/codegen"
 	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
 )
 
@@ -37,6 +38,14 @@ type ResourceStats struct {
 
 	// OutputPropertiesMissingDescriptions is the total number of all resource output properties missing descriptions.
 	OutputPropertiesMissingDescriptions int `json:"output_properties_missing_descriptions"`
+
+	// EnumProperties is the total number of resource input and output properties, including nested types, whose
+	// type is an enum, or an array or map of them.
+	EnumProperties int `json:"enum_properties"`
+
+	// DiscriminatedUnionProperties is the total number of resource input and output properties, including nested
+	// types, whose type is a union of types with a discriminator.
+	DiscriminatedUnionProperties int `json:"discriminated_union_properties"`
 }
 
 // FunctionStats contain statistics relating to the functions section of a Pulumi schema.
@@ -48,6 +57,9 @@ type FunctionStats struct {
 	// not including inputs and outputs.
 	TotalDescriptionBytes int `json:"total_description_bytes"`
 
+	// TotalInputProperties is the total number of function input properties.
+	TotalInputProperties int `json:"total_input_properties"`
+
 	// TotalInputPropertyDescriptionBytes is the sum total of all bytes in descriptions of function input properties,
 	// not including the input type description.
 	TotalInputPropertyDescriptionBytes int `json:"total_input_property_description_bytes"`
@@ -55,155 +67,352 @@ type FunctionStats struct {
 	// InputPropertiesMissingDescriptions is the total number of all function input properties missing descriptions.
 	InputPropertiesMissingDescriptions int `json:"input_properties_missing_descriptions"`
 
+	// TotalOutputProperties is the total number of function output properties.
+	TotalOutputProperties int `json:"total_output_properties"`
+
 	// TotalOutputPropertyDescriptionBytes is the sum total of all bytes in description of function output properties,
 	// not include the output type description.
 	TotalOutputPropertyDescriptionBytes int `json:"total_output_property_description_bytes"`
 
 	// OutputPropertiesMissingDescriptions is the total number of all function output properties missing descriptions.
 	OutputPropertiesMissingDescriptions int `json:"output_properties_missing_descriptions"`
+
+	// EnumProperties is the total number of function input and output properties whose type is an enum, or an
+	// array or map of them.
+	EnumProperties int `json:"enum_properties"`
+
+	// DiscriminatedUnionProperties is the total number of function input and output properties whose type is a
+	// union of types with a discriminator.
+	DiscriminatedUnionProperties int `json:"discriminated_union_properties"`
 }
 
+// propKind is whether a property is read as an input or as an output.
+type propKind int
+
+const (
+	inputKind propKind = iota
+	outputKind
+)
+
 func CountStats(sch schema.PackageSpec) PulumiSchemaStats {
 	stats := PulumiSchemaStats{
 		Resources: ResourceStats{},
 		Functions: FunctionStats{},
 	}
 
-	uniques := mapset.NewSet[string]()
-	visitedTypes := mapset.NewSet[string]()
+	counter := newStatsCounter(sch)
+	for n, r := range sch.Resources {
+		counter.addResource(&stats.Resources, n, r)
+	}
+	for _, f := range sch.Functions {
+		counter.addFunction(&stats.Functions, f)
+	}
+
+	return stats
+}
+
+// statsCounter adds up the stats of the resources and functions of a schema, counting each API
+// version of a resource and each nested type only once, however often they are reached.
+type statsCounter struct {
+	sch     schema.PackageSpec
+	uniques mapset.Set[string]
 
-	type propCountResult struct {
-		totalInputs        int
-		inputsMissingDesc  int
-		totalOutputs       int
-		outputsMissingDesc int
+	// A type reachable both from inputs and from outputs contributes to both totals, so we
+	// track visitation separately per kind.
+	visitedTypes map[propKind]mapset.Set[string]
+}
+
+type propCountResult struct {
+	total       int
+	missingDesc int
+	enums       int
+	unions      int
+}
+
+func newStatsCounter(sch schema.PackageSpec) *statsCounter {
+	return &statsCounter{
+		sch:     sch,
+		uniques: mapset.NewSet[string](),
+		visitedTypes: map[propKind]mapset.Set[string]{
+			inputKind:  mapset.NewSet[string](),
+			outputKind: mapset.NewSet[string](),
+		},
 	}
+}
 
-	var propCount func(string) propCountResult
-	propCount = func(typeName string) propCountResult {
-		if visitedTypes.Contains(typeName) {
-			return propCountResult{}
-		}
+// propKinds returns whether the property is an enum, or an array or map of them, and whether it is a
+// discriminated union, as 0 or 1.
+func (c *statsCounter) propKinds(p schema.PropertySpec) (enums int, unions int) {
+	if c.isEnum(&p.TypeSpec) {
+		enums = 1
+	}
+	if len(p.OneOf) > 0 && p.Discriminator != nil {
+		unions = 1
+	}
+	return enums, unions
+}
 
-		res := propCountResult{}
+// isEnum reports whether ts refers to an enum type, directly or as the elements of arrays and maps.
+func (c *statsCounter) isEnum(ts *schema.TypeSpec) bool {
+	switch {
+	case ts == nil:
+		return false
+	case ts.Ref != "":
+		token, ok := LocalTypeToken(ts.Ref)
+		return ok && len(c.sch.Types[token].Enum) > 0
+	case ts.Items != nil:
+		return c.isEnum(ts.Items)
+	default:
+		return c.isEnum(ts.AdditionalProperties)
+	}
+}
 
-		visitedTypes.Add(typeName)
+// propCount counts the properties of a type, and of the types it references, as properties of the given kind.
+func (c *statsCounter) propCount(typeName string, kind propKind) propCountResult {
+	visited := c.visitedTypes[kind]
+	if visited.Contains(typeName) {
+		return propCountResult{}
+	}
+	visited.Add(typeName)
 
-		t := sch.Types[typeName]
+	t := c.sch.Types[typeName]
 
-		res.totalInputs = len(t.Properties)
+	res := propCountResult{total: len(t.Properties)}
 
-		for _, input := range t.Properties {
-			if input.Description == "" {
-				res.inputsMissingDesc++
-			}
+	for _, prop := range t.Properties {
+		if prop.Description == "" {
+			res.missingDesc++
+		}
 
-			if input.Ref != "" {
-				tn := strings.TrimPrefix(input.Ref, "#/types/")
-				nestedRes := propCount(tn)
+		enums, unions := c.propKinds(prop)
+		res.enums += enums
+		res.unions += unions
 
-				res.totalInputs += nestedRes.totalInputs
-				res.totalOutputs += nestedRes.totalOutputs
-				res.inputsMissingDesc += nestedRes.inputsMissingDesc
-				res.outputsMissingDesc += nestedRes.outputsMissingDesc
-			}
+		if prop.Ref != "" {
+			tn := strings.TrimPrefix(prop.Ref, "#/types/")
+			nestedRes := c.propCount(tn, kind)
+
+			res.total += nestedRes.total
+			res.missingDesc += nestedRes.missingDesc
+			res.enums += nestedRes.enums
+			res.unions += nestedRes.unions
 		}
+	}
 
-		res.totalOutputs = len(t.ObjectTypeSpec.Properties)
+	return res
+}
 
-		for _, output := range t.ObjectTypeSpec.Properties {
-			if output.Description == "" {
-				res.outputsMissingDesc++
-			}
+// addResource adds the stats of the resource n to stats, unless another API version of it was
+// added before.
+func (c *statsCounter) addResource(stats *ResourceStats, n string, r schema.ResourceSpec) {
+	baseName := VersionlessName(n)
+	if c.uniques.Contains(baseName) {
+		return
+	}
+	c.uniques.Add(baseName)
 
-			if output.Ref != "" {
-				tn := strings.TrimPrefix(output.Ref, "#/types/")
-				nestedRes := propCount(tn)
+	stats.TotalResources++
+	stats.TotalInputProperties += len(r.InputProperties)
+	stats.TotalDescriptionBytes += len(r.Description)
 
-				res.totalInputs += nestedRes.totalInputs
-				res.totalOutputs += nestedRes.totalOutputs
-				res.inputsMissingDesc += nestedRes.inputsMissingDesc
-				res.outputsMissingDesc += nestedRes.outputsMissingDesc
-			}
+	for _, input := range r.InputProperties {
+		if input.Description == "" {
+			stats.InputPropertiesMissingDescriptions++
 		}
 
-		return res
+		enums, unions := c.propKinds(input)
+		stats.EnumProperties += enums
+		stats.DiscriminatedUnionProperties += unions
+
+		if input.Ref != "" {
+			typeName := strings.TrimPrefix(input.Ref, "#/types/")
+			res := c.propCount(typeName, inputKind)
+			stats.TotalInputProperties += res.total
+			stats.InputPropertiesMissingDescriptions += res.missingDesc
+			stats.EnumProperties += res.enums
+			stats.DiscriminatedUnionProperties += res.unions
+		}
 	}
 
-	for n, r := range sch.Resources {
-		baseName := VersionlessName(n)
-		if uniques.Contains(baseName) {
-			continue
+	stats.TotalOutputProperties += len(r.ObjectTypeSpec.Properties)
+
+	for _, output := range r.ObjectTypeSpec.Properties {
+		if output.Description == "" {
+			stats.OutputPropertiesMissingDescriptions++
 		}
-		uniques.Add(baseName)
 
-		stats.Resources.TotalInputProperties += len(r.InputProperties)
-		stats.Resources.TotalDescriptionBytes += len(r.Description)
+		enums, unions := c.propKinds(output)
+		stats.EnumProperties += enums
+		stats.DiscriminatedUnionProperties += unions
+
+		if output.Ref != "" {
+			typeName := strings.TrimPrefix(output.Ref, "#/types/")
+			res := c.propCount(typeName, outputKind)
+			stats.TotalOutputProperties += res.total
+			stats.OutputPropertiesMissingDescriptions += res.missingDesc
+			stats.EnumProperties += res.enums
+			stats.DiscriminatedUnionProperties += res.unions
+		}
+	}
+}
 
-		for _, input := range r.InputProperties {
-			if input.Description == "" {
-				stats.Resources.InputPropertiesMissingDescriptions++
+// addFunction adds the stats of the function v to stats.
+func (c *statsCounter) addFunction(stats *FunctionStats, v schema.FunctionSpec) {
+	stats.TotalFunctions++
+	stats.TotalDescriptionBytes += len(v.Description)
+
+	if v.Inputs != nil && v.Inputs.Properties != nil {
+		stats.TotalInputProperties += len(v.Inputs.Properties)
+		for _, vv := range v.Inputs.Properties {
+			stats.TotalInputPropertyDescriptionBytes += len(vv.Description)
+			if vv.Description == "" {
+				stats.InputPropertiesMissingDescriptions++
 			}
 
-			if input.Ref != "" {
-				typeName := strings.TrimPrefix(input.Ref, "#/types/")
-				res := propCount(typeName)
-				stats.Resources.TotalInputProperties += res.totalInputs
-				stats.Resources.InputPropertiesMissingDescriptions += res.inputsMissingDesc
-				stats.Resources.TotalOutputProperties += res.totalOutputs
-				stats.Resources.OutputPropertiesMissingDescriptions += res.outputsMissingDesc
-			}
+			enums, unions := c.propKinds(vv)
+			stats.EnumProperties += enums
+			stats.DiscriminatedUnionProperties += unions
 		}
+	}
 
-		stats.Resources.TotalOutputProperties += len(r.ObjectTypeSpec.Properties)
-
-		for _, output := range r.ObjectTypeSpec.Properties {
-			if output.Description == "" {
-				stats.Resources.OutputPropertiesMissingDescriptions++
+	if v.Outputs != nil && v.Outputs.Properties != nil {
+		stats.TotalOutputProperties += len(v.Outputs.Properties)
+		for _, vv := range v.Outputs.Properties {
+			stats.TotalOutputPropertyDescriptionBytes += len(vv.Description)
+			if vv.Description == "" {
+				stats.OutputPropertiesMissingDescriptions++
 			}
 
-			if output.Ref != "" {
-				typeName := strings.TrimPrefix(output.Ref, "#/types/")
-				res := propCount(typeName)
-				stats.Resources.TotalInputProperties += res.totalInputs
-				stats.Resources.InputPropertiesMissingDescriptions += res.inputsMissingDesc
-				stats.Resources.TotalOutputProperties += res.totalOutputs
-				stats.Resources.OutputPropertiesMissingDescriptions += res.outputsMissingDesc
-			}
+			enums, unions := c.propKinds(vv)
+			stats.EnumProperties += enums
+			stats.DiscriminatedUnionProperties += unions
+		}
+	}
+}
+
+// StatsByModule computes resource statistics separately for each module of the schema. Modules are keyed by
+// the module component of the resource tokens, e.g. "ec2" for "aws:ec2/instance:Instance".
+func StatsByModule(sch schema.PackageSpec) map[string]ResourceStats {
+	modules := map[string]map[string]schema.ResourceSpec{}
+	for token, r := range sch.Resources {
+		mod := ModuleName(token)
+		if modules[mod] == nil {
+			modules[mod] = map[string]schema.ResourceSpec{}
 		}
+		modules[mod][token] = r
 	}
 
-	stats.Resources.TotalResources = uniques.Cardinality()
+	result := make(map[string]ResourceStats, len(modules))
+	for mod, resources := range modules {
+		modSch := sch
+		modSch.Resources = resources
+		modSch.Functions = nil
+		result[mod] = CountStats(modSch).Resources
+	}
+	return result
+}
 
-	stats.Functions.TotalFunctions = len(sch.Functions)
-	for _, v := range sch.Functions {
-		stats.Functions.TotalDescriptionBytes += len(v.Description)
+// ModuleName returns the module component of a token, without any version or file suffix:
+// "aws:ec2/instance:Instance" -> "ec2". Tokens without a module component yield "".
+func ModuleName(token string) string {
+	parts := strings.Split(token, ":")
+	if len(parts) < 3 {
+		return ""
+	}
+	mod, _, _ := strings.Cut(parts[1], "/")
+	return mod
+}
 
-		if v.Inputs != nil && v.Inputs.Properties != nil {
-			for _, vv := range v.Inputs.Properties {
-				stats.Functions.TotalInputPropertyDescriptionBytes += len(vv.Description)
-				if vv.Description == "" {
-					stats.Functions.InputPropertiesMissingDescriptions++
-				}
-			}
-		}
+// DescriptionCoverage returns the share of resource and function properties, including those of
+// nested resource types, that have a description. A schema without properties is fully covered.
+func DescriptionCoverage(stats PulumiSchemaStats) float64 {
+	total := stats.Resources.TotalInputProperties + stats.Resources.TotalOutputProperties +
+		stats.Functions.TotalInputProperties + stats.Functions.TotalOutputProperties
+	if total == 0 {
+		return 1
+	}
+	missing := stats.Resources.InputPropertiesMissingDescriptions +
+		stats.Resources.OutputPropertiesMissingDescriptions +
+		stats.Functions.InputPropertiesMissingDescriptions +
+		stats.Functions.OutputPropertiesMissingDescriptions
+	return float64(total-missing) / float64(total)
+}
 
-		if v.Outputs != nil && v.Outputs.Properties != nil {
-			for _, vv := range v.Outputs.Properties {
-				stats.Functions.TotalOutputPropertyDescriptionBytes += len(vv.Description)
-				if vv.Description == "" {
-					stats.Functions.OutputPropertiesMissingDescriptions++
-				}
-			}
+// MissingDescriptionsByToken returns the number of properties missing a description for each
+// resource and function of the schema, counted as in CountStats so that the numbers add up to its
+// totals: a nested type shared by several resources, and a resource with several API versions,
+// count towards the first token in sorted order. Tokens whose properties are all documented are
+// left out.
+func MissingDescriptionsByToken(sch schema.PackageSpec) map[string]int {
+	missing := map[string]int{}
+	count := func(token string, stats PulumiSchemaStats) {
+		n := stats.Resources.InputPropertiesMissingDescriptions +
+			stats.Resources.OutputPropertiesMissingDescriptions +
+			stats.Functions.InputPropertiesMissingDescriptions +
+			stats.Functions.OutputPropertiesMissingDescriptions
+		if n > 0 {
+			missing[token] = n
 		}
 	}
 
-	return stats
+	counter := newStatsCounter(sch)
+	for _, token := range codegen.SortedKeys(sch.Resources) {
+		var stats PulumiSchemaStats
+		counter.addResource(&stats.Resources, token, sch.Resources[token])
+		count(token, stats)
+	}
+	for _, token := range codegen.SortedKeys(sch.Functions) {
+		var stats PulumiSchemaStats
+		counter.addFunction(&stats.Functions, sch.Functions[token])
+		count(token, stats)
+	}
+	return missing
 }
 
-// "azure-native:appplatform/v20230101preview" -> "appplatform"
+// DiffStats returns the change in each statistic from old to new.
+func DiffStats(old, new PulumiSchemaStats) PulumiSchemaStats {
+	return PulumiSchemaStats{
+		Functions: FunctionStats{
+			TotalFunctions:        new.Functions.TotalFunctions - old.Functions.TotalFunctions,
+			TotalDescriptionBytes: new.Functions.TotalDescriptionBytes - old.Functions.TotalDescriptionBytes,
+			TotalInputProperties:  new.Functions.TotalInputProperties - old.Functions.TotalInputProperties,
+			TotalInputPropertyDescriptionBytes: new.Functions.TotalInputPropertyDescriptionBytes -
+				old.Functions.TotalInputPropertyDescriptionBytes,
+			InputPropertiesMissingDescriptions: new.Functions.InputPropertiesMissingDescriptions -
+				old.Functions.InputPropertiesMissingDescriptions,
+			TotalOutputProperties: new.Functions.TotalOutputProperties - old.Functions.TotalOutputProperties,
+			TotalOutputPropertyDescriptionBytes: new.Functions.TotalOutputPropertyDescriptionBytes -
+				old.Functions.TotalOutputPropertyDescriptionBytes,
+			OutputPropertiesMissingDescriptions: new.Functions.OutputPropertiesMissingDescriptions -
+				old.Functions.OutputPropertiesMissingDescriptions,
+			EnumProperties: new.Functions.EnumProperties - old.Functions.EnumProperties,
+			DiscriminatedUnionProperties: new.Functions.DiscriminatedUnionProperties -
+				old.Functions.DiscriminatedUnionProperties,
+		},
+		Resources: ResourceStats{
+			TotalResources:        new.Resources.TotalResources - old.Resources.TotalResources,
+			TotalDescriptionBytes: new.Resources.TotalDescriptionBytes - old.Resources.TotalDescriptionBytes,
+			TotalInputProperties:  new.Resources.TotalInputProperties - old.Resources.TotalInputProperties,
+			InputPropertiesMissingDescriptions: new.Resources.InputPropertiesMissingDescriptions -
+				old.Resources.InputPropertiesMissingDescriptions,
+			TotalOutputProperties: new.Resources.TotalOutputProperties - old.Resources.TotalOutputProperties,
+			OutputPropertiesMissingDescriptions: new.Resources.OutputPropertiesMissingDescriptions -
+				old.Resources.OutputPropertiesMissingDescriptions,
+			EnumProperties: new.Resources.EnumProperties - old.Resources.EnumProperties,
+			DiscriminatedUnionProperties: new.Resources.DiscriminatedUnionProperties -
+				old.Resources.DiscriminatedUnionProperties,
+		},
+	}
+}
+
+// "azure-native:appplatform/v20230101preview:Spring" -> "appplatform:Spring"
+//
+// Names that aren't made of exactly three colon separated parts are returned unchanged.
 func VersionlessName(name string) string {
 	parts := strings.Split(name, ":")
+	if len(parts) != 3 {
+		return name
+	}
 	mod := parts[1]
 	modParts := strings.Split(mod, "/")
 	if len(modParts) == 2 {
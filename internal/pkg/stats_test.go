@@ -116,9 +116,11 @@ func TestCountStats(t *testing.T) {
 	assert.Equal(t, 2, stats.Functions.TotalFunctions)
 	assert.Equal(t, 11, stats.Functions.TotalDescriptionBytes)
 
+	assert.Equal(t, 6, stats.Functions.TotalInputProperties)
 	assert.Equal(t, 3, stats.Functions.InputPropertiesMissingDescriptions)
 	assert.Equal(t, 12, stats.Functions.TotalInputPropertyDescriptionBytes)
 
+	assert.Equal(t, 7, stats.Functions.TotalOutputProperties)
 	assert.Equal(t, 4, stats.Functions.OutputPropertiesMissingDescriptions)
 	assert.Equal(t, 13, stats.Functions.TotalOutputPropertyDescriptionBytes)
 }
@@ -233,6 +235,30 @@ func TestCountStats_NoInputs(t *testing.T) {
 	_ = CountStats(testSchema)
 }
 
+func TestCountStats_NoOutputs(t *testing.T) {
+	testSchema := schema.PackageSpec{
+		Functions: map[string]schema.FunctionSpec{
+			"test:index/doFoo:doFoo": {
+				Description: "A function that takes inputs but returns nothing.",
+				Inputs: &schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"input1": {
+							Description: "0123456789",
+						},
+						"inputMissingDesc1": {},
+					},
+				},
+			},
+		},
+	}
+
+	stats := CountStats(testSchema)
+
+	assert.Equal(t, 1, stats.Functions.TotalFunctions)
+	assert.Equal(t, 1, stats.Functions.InputPropertiesMissingDescriptions)
+	assert.Equal(t, 0, stats.Functions.OutputPropertiesMissingDescriptions)
+}
+
 func TestCountStats_ExternalRef(t *testing.T) {
 	testSchema := schema.PackageSpec{
 		Resources: map[string]schema.ResourceSpec{
@@ -258,4 +284,232 @@ func TestCountStats_ExternalRef(t *testing.T) {
 
 func TestVersionlessName(t *testing.T) {
 	assert.Equal(t, "config:assumeRoleWithWebIdentity", VersionlessName("#/types/aws:config/assumeRoleWithWebIdentity:assumeRoleWithWebIdentity"))
+	assert.Equal(t, "appplatform:Spring", VersionlessName("azure-native:appplatform/v20230101preview:Spring"))
+	assert.Equal(t, "pkg:Resource", VersionlessName("pkg:Resource"))
+	assert.Equal(t, "a:b:c:d", VersionlessName("a:b:c:d"))
+	assert.Equal(t, "", VersionlessName(""))
+}
+
+func TestDiffStats(t *testing.T) {
+	old := PulumiSchemaStats{
+		Functions: FunctionStats{TotalFunctions: 5, InputPropertiesMissingDescriptions: 3},
+		Resources: ResourceStats{TotalResources: 10, OutputPropertiesMissingDescriptions: 7},
+	}
+	new := PulumiSchemaStats{
+		Functions: FunctionStats{TotalFunctions: 6, InputPropertiesMissingDescriptions: 1},
+		Resources: ResourceStats{TotalResources: 10, OutputPropertiesMissingDescriptions: 9},
+	}
+
+	assert.Equal(t, PulumiSchemaStats{
+		Functions: FunctionStats{TotalFunctions: 1, InputPropertiesMissingDescriptions: -2},
+		Resources: ResourceStats{OutputPropertiesMissingDescriptions: 2},
+	}, DiffStats(old, new))
+}
+
+func TestCountStats_EnumsAndUnions(t *testing.T) {
+	enumRef := schema.PropertySpec{TypeSpec: schema.TypeSpec{Ref: "#/types/test:index/color:Color"}}
+	union := schema.PropertySpec{TypeSpec: schema.TypeSpec{
+		OneOf: []schema.TypeSpec{
+			{Ref: "#/types/test:index/cat:Cat"},
+			{Ref: "#/types/test:index/dog:Dog"},
+		},
+		Discriminator: &schema.DiscriminatorSpec{PropertyName: "kind"},
+	}}
+	testSchema := schema.PackageSpec{
+		Types: map[string]schema.ComplexTypeSpec{
+			"test:index/color:Color": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{Type: "string"},
+				Enum:           []schema.EnumValueSpec{{Value: "red"}, {Value: "blue"}},
+			},
+			"test:index/nested:Nested": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"color": enumRef,
+						"pet":   union,
+					},
+				},
+			},
+		},
+		Resources: map[string]schema.ResourceSpec{
+			"test:index/foo:Foo": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"color": enumRef,
+					},
+				},
+				InputProperties: map[string]schema.PropertySpec{
+					"color":  enumRef,
+					"colors": {TypeSpec: schema.TypeSpec{Type: "array", Items: &enumRef.TypeSpec}},
+					"pet":    union,
+					"nested": {TypeSpec: schema.TypeSpec{Ref: "#/types/test:index/nested:Nested"}},
+				},
+			},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			"test:index/getFoo:getFoo": {
+				Inputs: &schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{"color": enumRef},
+				},
+				Outputs: &schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"pet": union,
+						// A map of arrays of enums.
+						"palettes": {TypeSpec: schema.TypeSpec{Type: "object", AdditionalProperties: &schema.TypeSpec{
+							Type: "array", Items: &enumRef.TypeSpec,
+						}}},
+					},
+				},
+			},
+		},
+	}
+
+	stats := CountStats(testSchema)
+
+	assert.Equal(t, 4, stats.Resources.EnumProperties)
+	assert.Equal(t, 2, stats.Resources.DiscriminatedUnionProperties)
+	assert.Equal(t, 2, stats.Functions.EnumProperties)
+	assert.Equal(t, 1, stats.Functions.DiscriminatedUnionProperties)
+}
+
+func TestCountStats_SharedInputOutputType(t *testing.T) {
+	shared := schema.PropertySpec{TypeSpec: schema.TypeSpec{Ref: "#/types/test:index/shared:Shared"}}
+	testSchema := schema.PackageSpec{
+		Types: map[string]schema.ComplexTypeSpec{
+			"test:index/shared:Shared": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"documented":   {Description: "0123456789"},
+						"undocumented": {},
+					},
+				},
+			},
+		},
+		Resources: map[string]schema.ResourceSpec{
+			"test:index/foo:Foo": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{"shared": shared},
+				},
+				InputProperties: map[string]schema.PropertySpec{"shared": shared},
+			},
+		},
+	}
+
+	stats := CountStats(testSchema)
+
+	// The shared type is counted once as an input and once as an output.
+	assert.Equal(t, 3, stats.Resources.TotalInputProperties)
+	assert.Equal(t, 2, stats.Resources.InputPropertiesMissingDescriptions)
+	assert.Equal(t, 3, stats.Resources.TotalOutputProperties)
+	assert.Equal(t, 2, stats.Resources.OutputPropertiesMissingDescriptions)
+}
+
+func TestStatsByModule(t *testing.T) {
+	testSchema := schema.PackageSpec{
+		Resources: map[string]schema.ResourceSpec{
+			"aws:ec2/instance:Instance": {
+				InputProperties: map[string]schema.PropertySpec{
+					"ami":  {Description: "The AMI."},
+					"type": {},
+				},
+			},
+			"aws:ec2/vpc:Vpc": {
+				InputProperties: map[string]schema.PropertySpec{
+					"cidr": {},
+				},
+			},
+			"aws:s3/bucket:Bucket": {
+				InputProperties: map[string]schema.PropertySpec{
+					"acl": {Description: "The ACL."},
+				},
+			},
+		},
+	}
+
+	stats := StatsByModule(testSchema)
+
+	assert.Len(t, stats, 2)
+	assert.Equal(t, 2, stats["ec2"].TotalResources)
+	assert.Equal(t, 3, stats["ec2"].TotalInputProperties)
+	assert.Equal(t, 2, stats["ec2"].InputPropertiesMissingDescriptions)
+	assert.Equal(t, 1, stats["s3"].TotalResources)
+	assert.Equal(t, 0, stats["s3"].InputPropertiesMissingDescriptions)
+}
+
+func TestDescriptionCoverage(t *testing.T) {
+	testSchema := schema.PackageSpec{
+		Functions: map[string]schema.FunctionSpec{
+			"aws:ec2/getAmi:getAmi": {
+				Inputs: &schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"owner": {Description: "The owner."},
+					},
+				},
+				Outputs: &schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"id": {},
+					},
+				},
+			},
+		},
+		Resources: map[string]schema.ResourceSpec{
+			"aws:ec2/instance:Instance": {
+				InputProperties: map[string]schema.PropertySpec{
+					"ami":  {Description: "The AMI."},
+					"type": {},
+				},
+			},
+			"aws:s3/bucket:Bucket": {
+				InputProperties: map[string]schema.PropertySpec{
+					"acl": {Description: "The ACL."},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, 0.6, DescriptionCoverage(CountStats(testSchema)))
+	assert.Equal(t, 1.0, DescriptionCoverage(PulumiSchemaStats{}))
+	assert.Equal(t, map[string]int{
+		"aws:ec2/getAmi:getAmi":     1,
+		"aws:ec2/instance:Instance": 1,
+	}, MissingDescriptionsByToken(testSchema))
+}
+
+func TestMissingDescriptionsByTokenDedup(t *testing.T) {
+	settings := schema.PropertySpec{TypeSpec: schema.TypeSpec{Ref: "#/types/azure-native:app:Settings"}}
+	testSchema := schema.PackageSpec{
+		Resources: map[string]schema.ResourceSpec{
+			"azure-native:app/v20230101:App": {
+				InputProperties: map[string]schema.PropertySpec{"settings": settings, "name": {}},
+			},
+			"azure-native:app/v20240101:App": {
+				InputProperties: map[string]schema.PropertySpec{"settings": settings, "name": {}},
+			},
+			"azure-native:web:Site": {
+				InputProperties: map[string]schema.PropertySpec{"settings": settings},
+			},
+		},
+		Types: map[string]schema.ComplexTypeSpec{
+			"azure-native:app:Settings": {ObjectTypeSpec: schema.ObjectTypeSpec{
+				Type:       "object",
+				Properties: map[string]schema.PropertySpec{"a": {}, "b": {}},
+			}},
+		},
+	}
+
+	// The shared type and the second API version are only counted once, as in CountStats.
+	missing := MissingDescriptionsByToken(testSchema)
+	assert.Equal(t, map[string]int{
+		"azure-native:app/v20230101:App": 4,
+		"azure-native:web:Site":          1,
+	}, missing)
+	stats := CountStats(testSchema)
+	assert.Equal(t, stats.Resources.InputPropertiesMissingDescriptions,
+		missing["azure-native:app/v20230101:App"]+missing["azure-native:web:Site"])
+}
+
+func TestModuleName(t *testing.T) {
+	assert.Equal(t, "ec2", ModuleName("aws:ec2/instance:Instance"))
+	assert.Equal(t, "app", ModuleName("azure-native:app/v20230101:App"))
+	assert.Equal(t, "index", ModuleName("random:index:RandomString"))
+	assert.Equal(t, "", ModuleName("pkg:Resource"))
 }
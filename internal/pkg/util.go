@@ -8,12 +8,23 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
 )
 
+// DownloadTimeout bounds how long DownloadSchema may take when its context has no deadline of
+// its own, so that an unresponsive server can't hang a run forever.
+var DownloadTimeout = 5 * time.Minute
+
 func DownloadSchema(ctx context.Context, repositoryUrl string,
 	provider string, commit string) (schema.PackageSpec, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DownloadTimeout)
+		defer cancel()
+	}
+
 	var gitSource GitSource
 	// Support schematised URLS if the URL has a "schema" part we recognize
 	url, err := url.Parse(repositoryUrl)
@@ -41,17 +52,7 @@ func DownloadSchema(ctx context.Context, repositoryUrl string,
 	}
 	defer resp.Close()
 
-	body, err := io.ReadAll(resp)
-	if err != nil {
-		return schema.PackageSpec{}, err
-	}
-
-	var sch schema.PackageSpec
-	if err = json.Unmarshal(body, &sch); err != nil {
-		return schema.PackageSpec{}, err
-	}
-
-	return sch, nil
+	return LoadPackageSpec(resp)
 }
 
 func LoadLocalPackageSpec(filePath string) (schema.PackageSpec, error) {
@@ -59,11 +60,28 @@ func LoadLocalPackageSpec(filePath string) (schema.PackageSpec, error) {
 	if err != nil {
 		return schema.PackageSpec{}, err
 	}
+	return decodePackageSpec(filePath, body)
+}
 
-	var sch schema.PackageSpec
-	if err = json.Unmarshal(body, &sch); err != nil {
+// LoadPackageSpec decodes the schema read from r, e.g. an HTTP body or stdin.
+func LoadPackageSpec(r io.Reader) (schema.PackageSpec, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
 		return schema.PackageSpec{}, err
 	}
+	return LoadPackageSpecBytes(body)
+}
+
+// LoadPackageSpecBytes decodes the schema in body.
+func LoadPackageSpecBytes(body []byte) (schema.PackageSpec, error) {
+	return decodePackageSpec("", body)
+}
 
+// decodePackageSpec decodes body, read from filePath if it came from a file.
+func decodePackageSpec(filePath string, body []byte) (schema.PackageSpec, error) {
+	var sch schema.PackageSpec
+	if err := json.Unmarshal(body, &sch); err != nil {
+		return schema.PackageSpec{}, decodeError(filePath, body, err)
+	}
 	return sch, nil
 }
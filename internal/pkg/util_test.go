@@ -2,7 +2,9 @@ package pkg
 
 import (
 	"context"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/h2non/gock"
 	"github.com/stretchr/testify/assert"
@@ -59,6 +61,64 @@ func TestDownloadUnknownGithubRef(t *testing.T) {
 	assert.Equal(t, "404 HTTP error fetching schema from https://api.github.com/repos/pulumiverse/pulumi-unifi/contents/provider/cmd/pulumi-resource-unifi/schema.json?ref=unknown. If this is a private GitHub repository, try providing a token via the GITHUB_TOKEN environment variable. See: https://github.com/settings/tokens", err.Error())
 }
 
+func TestDownloadMissingGithubRef(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/repos/pulumiverse/pulumi-unifi/contents/provider/cmd/pulumi-resource-unifi/schema.json").
+		MatchParam("ref", "v9.9.9").
+		Reply(404)
+	gock.New("https://api.github.com").
+		Get("/repos/pulumiverse/pulumi-unifi$").
+		Reply(200).
+		JSON(map[string]string{"full_name": "pulumiverse/pulumi-unifi"})
+	gock.New("https://api.github.com").
+		Get("/repos/pulumiverse/pulumi-unifi/commits/v9.9.9").
+		Reply(422)
+
+	_, err := DownloadSchema(context.Background(),
+		"github://api.github.com/pulumiverse/pulumi-unifi", "unifi", "v9.9.9")
+
+	assert.EqualError(t, err,
+		`ref "v9.9.9" not found in pulumiverse/pulumi-unifi: check that the branch, tag or commit exists`)
+}
+
+func TestDownloadValidGithubEnterprise(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://ghe.mycorp.com").
+		Get("/api/v3/repos/pulumiverse/pulumi-unifi/contents/provider/cmd/pulumi-resource-unifi/schema.json").
+		MatchParam("ref", "main").
+		Times(2).
+		Reply(200).
+		File("schema.json")
+
+	for _, repositoryURL := range []string{
+		"github://ghe.mycorp.com/pulumiverse/pulumi-unifi",
+		"github://ghe.mycorp.com/api/v3/pulumiverse/pulumi-unifi",
+	} {
+		spec, err := DownloadSchema(context.Background(), repositoryURL, "unifi", "main")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "test", spec.Name)
+	}
+}
+
+func TestDownloadUnknownGithubEnterpriseRef(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://ghe.mycorp.com").
+		Get("/api/v3/repos/pulumiverse/pulumi-unifi/contents/provider/cmd/pulumi-resource-unifi/schema.json").
+		MatchParam("ref", "unknown").
+		Reply(404)
+
+	_, err := DownloadSchema(context.Background(),
+		"github://ghe.mycorp.com/pulumiverse/pulumi-unifi", "unifi", "unknown")
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "404 HTTP error fetching schema from https://ghe.mycorp.com/api/v3/repos/pulumiverse/pulumi-unifi/contents/provider/cmd/pulumi-resource-unifi/schema.json?ref=unknown. If this is a private GitHub repository, try providing a token via the GITHUB_TOKEN environment variable. See: https://github.com/settings/tokens", err.Error())
+}
+
 func TestDownloadValidGitlabOwner(t *testing.T) {
 	defer gock.Off()
 
@@ -106,3 +166,90 @@ func TestDownloadUnknownGitlabRef(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, "404 HTTP error fetching schema from https://gitlab.com/api/v4/projects/pulumiverse%2Fpulumi-unifi/repository/files/provider%2Fcmd%2Fpulumi-resource-unifi%2Fschema.json/raw?ref=unknown", err.Error())
 }
+
+func TestGithubTokenFallback(t *testing.T) {
+	tests := []struct {
+		name                  string
+		githubToken, ghToken  string
+		expectedAuthorization string
+	}{
+		{"GITHUB_TOKEN", "github-token", "", "token github-token"},
+		{"GH_TOKEN", "", "gh-token", "token gh-token"},
+		{"GITHUB_TOKEN first", "github-token", "gh-token", "token github-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+			t.Setenv("GITHUB_TOKEN", tt.githubToken)
+			t.Setenv("GH_TOKEN", tt.ghToken)
+
+			gock.New("https://api.github.com").
+				Get("/repos/pulumiverse/pulumi-unifi/contents/provider/cmd/pulumi-resource-unifi/schema.json").
+				MatchHeader("Authorization", tt.expectedAuthorization).
+				Reply(200).
+				File("schema.json")
+
+			_, err := DownloadSchema(context.Background(),
+				"github://api.github.com/pulumiverse/pulumi-unifi", "unifi", "main")
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestGitlabTokenFallback(t *testing.T) {
+	const path = "/api/v4/projects/pulumiverse/pulumi-unifi/repository/files/provider/cmd/pulumi-resource-unifi/schema.json/raw"
+
+	t.Run("GITLAB_TOKEN first", func(t *testing.T) {
+		defer gock.Off()
+		t.Setenv("GITLAB_TOKEN", "gitlab-token")
+		t.Setenv("CI_JOB_TOKEN", "job-token")
+
+		gock.New("https://gitlab.com").Get(path).
+			MatchHeader("Authorization", "Bearer gitlab-token").
+			Reply(200).
+			File("schema.json")
+
+		_, err := DownloadSchema(context.Background(), "gitlab://gitlab.com/pulumiverse/pulumi-unifi", "unifi", "main")
+		assert.Nil(t, err)
+	})
+
+	t.Run("CI_JOB_TOKEN", func(t *testing.T) {
+		defer gock.Off()
+		t.Setenv("GITLAB_TOKEN", "")
+		t.Setenv("CI_JOB_TOKEN", "job-token")
+
+		// Job tokens have a header of their own.
+		gock.New("https://gitlab.com").Get(path).
+			MatchHeader("JOB-TOKEN", "job-token").
+			Reply(200).
+			File("schema.json")
+
+		source, err := newGitlabSource(&url.URL{Scheme: "gitlab", Host: "gitlab.com", Path: "/pulumiverse"}, "unifi")
+		assert.Nil(t, err)
+		req, err := source.newHTTPRequest(context.Background(), "https://gitlab.com"+path, "")
+		assert.Nil(t, err)
+		assert.Empty(t, req.Header.Get("Authorization"))
+
+		_, err = DownloadSchema(context.Background(), "gitlab://gitlab.com/pulumiverse/pulumi-unifi", "unifi", "main")
+		assert.Nil(t, err)
+	})
+}
+
+func TestDownloadTimeout(t *testing.T) {
+	defer gock.Off()
+	timeout := DownloadTimeout
+	defer func() { DownloadTimeout = timeout }()
+	DownloadTimeout = 10 * time.Millisecond
+
+	gock.New("https://api.github.com").
+		Get("/repos/pulumiverse/pulumi-unifi/contents/provider/cmd/pulumi-resource-unifi/schema.json").
+		MatchParam("ref", "main").
+		Reply(200).
+		Delay(time.Second).
+		File("schema.json")
+
+	_, err := DownloadSchema(context.Background(),
+		"github://api.github.com/pulumiverse", "unifi", "main")
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+)
+
+// ValidatePackageSpec runs structural checks on a schema that was decoded without errors, but
+// may still not be a usable Pulumi schema: decoding the wrong JSON file into a PackageSpec
+// usually succeeds and leaves it empty.
+//
+// Every problem found is reported, joined into a single error.
+func ValidatePackageSpec(sch schema.PackageSpec) error {
+	var errs []error
+	if sch.Name == "" {
+		errs = append(errs, fmt.Errorf("schema has no name, is this a Pulumi schema?"))
+	}
+	if len(sch.Resources) == 0 && len(sch.Functions) == 0 && len(sch.Types) == 0 {
+		errs = append(errs, fmt.Errorf("schema has no resources, functions or types, is this the right file?"))
+	}
+	for _, err := range ValidateRefs(sch) {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// decodeError adds the file and the line and column where decoding failed to err, when they are
+// known. filePath is empty when the schema didn't come from a file.
+func decodeError(filePath string, body []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		if filePath == "" {
+			return err
+		}
+		return fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	line, column := 1, 1
+	for _, b := range body[:min(offset, int64(len(body)))] {
+		if b == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	if filePath == "" {
+		return fmt.Errorf("line %d, column %d: %w", line, column, err)
+	}
+	return fmt.Errorf("%s:%d:%d: %w", filePath, line, column, err)
+}
@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePackageSpec(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		sch, err := LoadLocalPackageSpec("schema.json")
+		assert.NoError(t, err)
+		assert.NoError(t, ValidatePackageSpec(sch))
+	})
+
+	t.Run("wrong file", func(t *testing.T) {
+		err := ValidatePackageSpec(schema.PackageSpec{})
+		assert.EqualError(t, err, "schema has no name, is this a Pulumi schema?\n"+
+			"schema has no resources, functions or types, is this the right file?")
+	})
+
+	t.Run("dangling reference", func(t *testing.T) {
+		err := ValidatePackageSpec(schema.PackageSpec{
+			Name: "test",
+			Resources: map[string]schema.ResourceSpec{
+				"test:index:Foo": {InputProperties: map[string]schema.PropertySpec{
+					"bar": {TypeSpec: schema.TypeSpec{Ref: "#/types/test:index:Bar"}},
+				}},
+			},
+		})
+		assert.EqualError(t, err,
+			`resources.test:index:Foo.inputProperties.bar: "#/types/test:index:Bar" does not resolve to a type in the schema`)
+	})
+}
+
+func TestLoadLocalPackageSpecErrorLocation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	err := os.WriteFile(path, []byte("{\n  \"name\": \"test\",\n  \"resources\": 42\n}\n"), 0o600)
+	assert.NoError(t, err)
+
+	_, err = LoadLocalPackageSpec(path)
+	assert.ErrorContains(t, err, path+":3:18: json: cannot unmarshal number")
+}
+
+func TestLoadPackageSpec(t *testing.T) {
+	sch, err := LoadPackageSpec(strings.NewReader(`{"name": "test", "version": "1.0.0"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "test", sch.Name)
+	assert.Equal(t, "1.0.0", sch.Version)
+
+	_, err = LoadPackageSpecBytes([]byte("{\n  \"name\": \"test\",\n  \"resources\": 42\n}\n"))
+	assert.ErrorContains(t, err, "line 3, column 18: json: cannot unmarshal number")
+}
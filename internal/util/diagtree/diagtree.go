@@ -13,6 +13,9 @@ type Node struct {
 	Title       string
 	Description string
 	Severity    Severity
+	// Code is a stable identifier for the kind of diagnostic described by this node. Unlike
+	// the description, it does not change when the wording of a message is tweaked.
+	Code Code
 
 	subfields []*Node
 	doDisplay bool
@@ -57,23 +60,49 @@ func (m *Node) Prune() {
 	m.subfields = sfs
 }
 
-func (m *Node) levelPrefix(level int) string {
+// DisplayOptions control how a tree is rendered by DisplayWith.
+type DisplayOptions struct {
+	// Headings holds the prefixes of the titles on the first two levels of the tree.
+	Headings [2]string
+	// Indent is repeated once for each level of nesting below the headings.
+	Indent string
+	// Bullet prefixes each title below the headings.
+	Bullet string
+	// SeverityMarkers controls whether the emoji marking the severity of a node is displayed.
+	SeverityMarkers bool
+}
+
+var (
+	// MarkdownDisplayOptions renders a tree as Markdown headings and lists. This is what Display uses.
+	MarkdownDisplayOptions = DisplayOptions{
+		Headings:        [2]string{"### ", "#### "},
+		Indent:          "    ",
+		Bullet:          "- ",
+		SeverityMarkers: true,
+	}
+	// PlainDisplayOptions renders a tree as indented plain text, for logs and chat messages.
+	PlainDisplayOptions = DisplayOptions{
+		Indent: "  ",
+		Bullet: "* ",
+	}
+)
+
+func (opts DisplayOptions) levelPrefix(level int) string {
 	switch level {
-	case 0:
-		return "### "
-	case 1:
-		return "#### "
+	case 0, 1:
+		return opts.Headings[level]
 	}
 	if level < 0 {
 		return ""
 	}
-	return strings.Repeat("  ", (level-2)*2) + "- "
+	return strings.Repeat(opts.Indent, level-2) + opts.Bullet
 }
 
 type cappedWriter struct {
 	// The number of remaining writes before we hit the cap.
 	remaining int
 	out       io.Writer
+	opts      DisplayOptions
 }
 
 func (c *cappedWriter) incr() {
@@ -92,7 +121,12 @@ func (c *cappedWriter) Write(p []byte) (n int, err error) {
 }
 
 func (m *Node) Display(out io.Writer, max int) int {
-	writer := &cappedWriter{max, out}
+	return m.DisplayWith(out, max, MarkdownDisplayOptions)
+}
+
+// DisplayWith is like Display, but renders the tree according to opts.
+func (m *Node) DisplayWith(out io.Writer, max int, opts DisplayOptions) int {
+	writer := &cappedWriter{max, out, opts}
 	return m.display(writer, 0, true)
 }
 
@@ -110,10 +144,10 @@ func (m *Node) display(out *cappedWriter, level int, prefix bool) int {
 	var display string
 	if m.Title != "" {
 		if prefix {
-			display = m.levelPrefix(level)
+			display = out.opts.levelPrefix(level)
 			// levels 0 & 1 are always top level, so we special case them
 			// here.
-			if level > 1 || m.Severity != None {
+			if out.opts.SeverityMarkers && (level > 1 || m.Severity != None) {
 				display += m.severity()
 			}
 		}
@@ -134,19 +168,8 @@ func (m *Node) display(out *cappedWriter, level int, prefix bool) int {
 		}
 	}
 
-	order := make([]int, len(m.subfields))
-	for i := range order {
-		order[i] = i
-	}
-	if level > 0 {
-		// Obtain an ordering on the subfields without mutating `.Subfields`.
-		sort.Slice(order, func(i, j int) bool {
-			return m.subfields[order[i]].Title < m.subfields[order[j]].Title
-		})
-	}
-
 	var didEndLine bool
-	for _, i := range order {
+	for _, i := range m.displayOrder(level) {
 		if m.subfields[i].doDisplay && !didEndLine {
 			if level > 1 {
 				write(":\n")
@@ -166,6 +189,69 @@ func (m *Node) display(out *cappedWriter, level int, prefix bool) int {
 	return displayed
 }
 
+// JSONNode is the serializable form of a displayed Node, for consumers that want to keep the
+// structure of the tree, e.g. to render it as collapsible sections.
+type JSONNode struct {
+	// Title is the title of the node as displayed, with values quoted.
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Severity    string     `json:"severity,omitempty"`
+	Code        Code       `json:"code,omitempty"`
+	Children    []JSONNode `json:"children,omitempty"`
+}
+
+// ToJSON returns the displayed part of the tree rooted at m, with children in display order.
+func (m *Node) ToJSON() JSONNode {
+	return m.toJSON(0)
+}
+
+func (m *Node) toJSON(level int) JSONNode {
+	n := JSONNode{
+		Title:       m.Title,
+		Description: m.Description,
+		Severity:    m.Severity.name,
+		Code:        m.Code,
+	}
+	for _, i := range m.displayOrder(level) {
+		if child := m.subfields[i]; child.doDisplay {
+			n.Children = append(n.Children, child.toJSON(level+1))
+		}
+	}
+	return n
+}
+
+// displayOrder returns the order in which the subfields of m, displayed at level, are visited.
+func (m *Node) displayOrder(level int) []int {
+	order := make([]int, len(m.subfields))
+	for i := range order {
+		order[i] = i
+	}
+	if level > 0 {
+		// Obtain an ordering on the subfields without mutating `.Subfields`.
+		//
+		// Titles are usually unique, but we break ties on the rest of the node so that
+		// the output is byte-for-byte identical across runs.
+		sort.SliceStable(order, func(i, j int) bool {
+			return m.subfields[order[i]].less(m.subfields[order[j]])
+		})
+	}
+	return order
+}
+
+// less defines a total order on nodes, by title first and then by the diagnostic they carry.
+func (m *Node) less(o *Node) bool {
+	if m.Title != o.Title {
+		return m.Title < o.Title
+	}
+	if m.Severity.name != o.Severity.name {
+		return m.Severity.name < o.Severity.name
+	}
+	if m.Description != o.Description {
+		return m.Description < o.Description
+	}
+	return m.Code < o.Code
+}
+
 // Find the unique successor node for m.
 //
 // If there is no successor or if there are multiple successors, nil is returned.
@@ -203,19 +289,41 @@ func (m *Node) severity() string {
 // The severity of a node.
 //
 // Nodes with their own (non-None) severity are always displayed on their own level.
-type Severity struct{ s string }
+type Severity struct{ s, name string }
 
 var (
-	None   = Severity{""}
-	Info   = Severity{"`🟢`"}
-	Warn   = Severity{"`🟡`"}
-	Danger = Severity{"`🔴`"}
+	None   = Severity{"", ""}
+	Info   = Severity{"`🟢`", "info"}
+	Warn   = Severity{"`🟡`", "warn"}
+	Danger = Severity{"`🔴`", "danger"}
 )
 
 func (s Severity) String() string {
 	return s.s
 }
 
+// Name returns a plain text name for the severity, suitable for machine readable output.
+func (s Severity) Name() string {
+	return s.name
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.name), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for _, v := range []Severity{None, Info, Warn, Danger} {
+		if v.name == string(text) {
+			*s = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// Code identifies the kind of a diagnostic.
+type Code string
+
 func (m *Node) SetDescription(level Severity, msg string, a ...any) {
 	for v := m; v != nil && !v.doDisplay; v = v.parent {
 		v.doDisplay = true
@@ -223,3 +331,70 @@ func (m *Node) SetDescription(level Severity, msg string, a ...any) {
 	m.Description = fmt.Sprintf(msg, a...)
 	m.Severity = level
 }
+
+// SetDiagnostic is like SetDescription, but also records the code of the diagnostic.
+func (m *Node) SetDiagnostic(code Code, level Severity, msg string, a ...any) {
+	m.SetDescription(level, msg, a...)
+	m.Code = code
+}
+
+// WalkDisplayed calls f on each displayed node that carries a description, in display order.
+//
+// path holds the titles of the nodes from the root down to and including the visited node.
+// Nodes without a title are omitted from the path.
+func (m *Node) WalkDisplayed(f func(path []string, n *Node)) {
+	m.walkDisplayed(nil, 0, f)
+}
+
+// Filter clears the diagnostics of the displayed nodes for which keep returns false, and stops
+// displaying the branches that no longer lead to a diagnostic.
+//
+// path is as in WalkDisplayed.
+func (m *Node) Filter(keep func(path []string, n *Node) bool) {
+	m.filter(nil, keep)
+	m.Prune()
+}
+
+func (m *Node) filter(path []string, keep func([]string, *Node) bool) bool {
+	if m == nil || !m.doDisplay {
+		return false
+	}
+	if m.Title != "" {
+		path = append(path[:len(path):len(path)], m.Title)
+	}
+	if m.Description != "" && !keep(path, m) {
+		m.Description, m.Severity, m.Code = "", None, ""
+	}
+	m.doDisplay = m.Description != ""
+	for _, v := range m.subfields {
+		if v.filter(path, keep) {
+			m.doDisplay = true
+		}
+	}
+	return m.doDisplay
+}
+
+// SeverityCounts returns the number of displayed diagnostics of each severity. Only nodes that
+// carry a description are counted.
+func (m *Node) SeverityCounts() map[Severity]int {
+	counts := map[Severity]int{}
+	m.WalkDisplayed(func(_ []string, n *Node) {
+		counts[n.Severity]++
+	})
+	return counts
+}
+
+func (m *Node) walkDisplayed(path []string, level int, f func([]string, *Node)) {
+	if m == nil || !m.doDisplay {
+		return
+	}
+	if m.Title != "" {
+		path = append(path[:len(path):len(path)], m.Title)
+	}
+	if m.Description != "" {
+		f(path, m)
+	}
+	for _, i := range m.displayOrder(level) {
+		m.subfields[i].walkDisplayed(path, level+1, f)
+	}
+}
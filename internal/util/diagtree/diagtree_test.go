@@ -73,6 +73,79 @@ func TestPrunedDisplay(t *testing.T) {
 	}
 }
 
+func TestPlainDisplay(t *testing.T) {
+	t.Parallel()
+	n := &diagtree.Node{Title: "Top Level"}
+	l2 := n.Label("l1").Label("l2")
+	l2.SetDescription(diagtree.Info, "nested descriptions")
+	l2.Label("value1").SetDescription(diagtree.Warn, "warn")
+	l2.Label("value2").Label("deeper").SetDescription(diagtree.Danger, "danger")
+	l2.Label("value2").Label("other").SetDescription(diagtree.Danger, "danger")
+	n.Prune()
+
+	actual := new(bytes.Buffer)
+	count := n.DisplayWith(actual, -1, diagtree.PlainDisplayOptions)
+	assert.Equal(t, 4, count)
+	assert.Equal(t, "Top Level\nl1\n* l2 nested descriptions:\n  * value1 warn\n  * value2:\n    * deeper danger\n    * other danger\n",
+		actual.String())
+}
+
+func TestSeverityCounts(t *testing.T) {
+	t.Parallel()
+	n := &diagtree.Node{Title: "Top Level"}
+	l2 := n.Label("l1").Label("l2")
+	l2.SetDescription(diagtree.Info, "nested descriptions")
+	l2.Label("value1").SetDescription(diagtree.Warn, "warn")
+	l2.Label("value2").Label("deeper").SetDescription(diagtree.Danger, "danger")
+	l2.Label("value3").SetDescription(diagtree.Danger, "danger")
+	n.Label("not displayed").Label("branch")
+
+	assert.Equal(t, map[diagtree.Severity]int{
+		diagtree.Info:   1,
+		diagtree.Warn:   1,
+		diagtree.Danger: 2,
+	}, n.SeverityCounts())
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+	n := &diagtree.Node{Title: "Top Level"}
+	l2 := n.Label("l1").Label("l2")
+	l2.Label("value1").SetDescription(diagtree.Warn, "warn")
+	l2.Label("value2").Label("deeper").SetDescription(diagtree.Danger, "danger")
+	n.Label("other").SetDescription(diagtree.Info, "info")
+	n.Prune()
+
+	n.Filter(func(path []string, _ *diagtree.Node) bool {
+		return path[len(path)-1] == "value1"
+	})
+
+	actual := new(bytes.Buffer)
+	count := n.Display(actual, -1)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "### Top Level\n#### l1\n- `🟡` l2: value1 warn\n", actual.String())
+}
+
+func TestToJSON(t *testing.T) {
+	t.Parallel()
+	n := &diagtree.Node{Title: "Top Level"}
+	l1 := n.Label("l1")
+	l1.Value("b").SetDiagnostic("CODE", diagtree.Warn, "warn")
+	l1.Label("a").SetDescription(diagtree.Info, "info")
+	n.Label("not displayed")
+
+	assert.Equal(t, diagtree.JSONNode{
+		Title: "Top Level",
+		Children: []diagtree.JSONNode{{
+			Title: "l1",
+			Children: []diagtree.JSONNode{
+				{Title: `"b"`, Description: "warn", Severity: "warn", Code: "CODE"},
+				{Title: "a", Description: "info", Severity: "info"},
+			},
+		}},
+	}, n.ToJSON())
+}
+
 type testCase struct {
 	input *diagtree.Node
 
@@ -0,0 +1,48 @@
+package diagtree
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisplayOrderBreaksTitleTies(t *testing.T) {
+	t.Parallel()
+
+	// Nodes with the same title can't be created through Label or Value, so we build
+	// them by hand.
+	build := func(children ...*Node) *Node {
+		root := &Node{Title: "Top Level"}
+		l1 := root.Label("l1")
+		for _, c := range children {
+			c.parent = l1
+			l1.subfields = append(l1.subfields, c)
+		}
+		for _, c := range children {
+			c.SetDiagnostic(c.Code, c.Severity, c.Description)
+		}
+		return root
+	}
+	nodes := func() []*Node {
+		return []*Node{
+			{Title: `"same"`, Severity: Warn, Description: "b", Code: "B"},
+			{Title: `"same"`, Severity: Danger, Description: "z", Code: "Z"},
+			{Title: `"same"`, Severity: Warn, Description: "a", Code: "A"},
+		}
+	}
+
+	forward := nodes()
+	reversed := nodes()
+	reversed[0], reversed[2] = reversed[2], reversed[0]
+
+	var a, b bytes.Buffer
+	build(forward...).Display(&a, -1)
+	build(reversed...).Display(&b, -1)
+
+	assert.Equal(t, a.String(), b.String())
+	assert.Equal(t, "### Top Level\n#### l1\n"+
+		"- `🔴` \"same\" z\n"+
+		"- `🟡` \"same\" a\n"+
+		"- `🟡` \"same\" b\n", a.String())
+}